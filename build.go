@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JustinKuli/policy-transformer/wrappers"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// RunBuild renders the kustomization at the given path, like `kustomize build`.
+// The wrapper configs in the kustomization are run by this binary, instead of
+// in the container image specified in their function annotation, so neither
+// docker nor network access is required for them. Any other exec
+// function in the kustomization is an error.
+func RunBuild(args []string, stdout, _ io.Writer) error {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: policy-transformer build [<kustomization dir>]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		return errors.New("at most one kustomization directory can be specified")
+	}
+
+	path := fs.Arg(0)
+	if path == "" {
+		path = "."
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	opts := krusty.MakeDefaultOptions()
+	opts.DoLegacyResourceSort = true // the default for `kustomize build`
+	opts.PluginConfig = types.MakePluginConfig(types.PluginRestrictionsNone, types.BploUseStaticallyLinked)
+	opts.PluginConfig.FnpLoadingOptions.EnableExec = true
+
+	fSys := nativeFS{FileSystem: filesys.MakeFsOnDisk(), self: self}
+
+	resMap, err := krusty.MakeKustomizer(opts).Run(fSys, path)
+	if err != nil {
+		return err
+	}
+
+	out, err := resMap.AsYaml()
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(out)
+
+	return err
+}
+
+// nativeFS is a FileSystem which replaces the function annotation on the
+// wrapper configs it reads, so that kustomize will run them with this binary as
+// an exec function.
+type nativeFS struct {
+	filesys.FileSystem
+	self string
+}
+
+func (n nativeFS) ReadFile(path string) ([]byte, error) {
+	content, err := n.FileSystem.ReadFile(path)
+	if err != nil {
+		return content, err
+	}
+
+	nodes, err := (&kio.ByteReader{
+		Reader:                bytes.NewReader(content),
+		OmitReaderAnnotations: true,
+	}).Read()
+	if err != nil {
+		// Not every file kustomize reads is yaml; leave those alone.
+		return content, nil //nolint:nilerr
+	}
+
+	isKustomization := isKustomizationFile(path)
+
+	found := false
+
+	for _, node := range nodes {
+		var changed bool
+
+		if isKustomization {
+			changed, err = n.setInlineExecFunctions(node, filepath.Dir(path))
+		} else {
+			changed, err = n.setExecFunction(node, filepath.Dir(path))
+		}
+
+		if err != nil {
+			return content, err
+		}
+
+		found = found || changed
+	}
+
+	if !found {
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+
+	err = kio.ByteWriter{Writer: &buf}.Write(nodes)
+
+	return buf.Bytes(), err
+}
+
+// setExecFunction replaces the function annotation on the node with one which
+// runs this binary, if the node is a wrapper config. It also sets the
+// wrappers.ManifestsRootAnnotation to the given directory, if it is not already
+// set. It returns whether the node was changed. Exec functions are enabled for
+// the whole kustomization so that these configs can run, which would also
+// allow any other exec function to run arbitrary commands; so an error is
+// returned if the node is any other exec function.
+func (n nativeFS) setExecFunction(node *yaml.RNode, dir string) (bool, error) {
+	spec := runtimeutil.GetFunctionSpec(node)
+	if spec == nil {
+		return false, nil
+	}
+
+	if !isSupportedKind(node.GetKind()) {
+		if spec.Exec.Path != "" {
+			return false, fmt.Errorf("the exec function '%v' in %v %v can not be run by the build command",
+				spec.Exec.Path, node.GetKind(), node.GetName())
+		}
+
+		return false, nil
+	}
+
+	for _, key := range []string{"config.kubernetes.io/function", "config.k8s.io/function"} {
+		_, err := yaml.ClearAnnotation(key).Filter(node)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err := node.PipeE(yaml.SetAnnotation("config.kubernetes.io/function", "exec:\n  path: "+n.self+"\n"))
+	if err != nil {
+		return false, err
+	}
+
+	if _, found := node.GetAnnotations()[wrappers.ManifestsRootAnnotation]; !found {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return false, err
+		}
+
+		err = node.PipeE(yaml.SetAnnotation(wrappers.ManifestsRootAnnotation, abs))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// setInlineExecFunctions calls setExecFunction on the configs which are inlined
+// as strings in the generators, transformers and validators of the
+// kustomization. It
+// returns whether any of them were changed.
+func (n nativeFS) setInlineExecFunctions(kustomization *yaml.RNode, dir string) (bool, error) {
+	found := false
+
+	for _, field := range []string{"generators", "transformers", "validators"} {
+		list, err := kustomization.Pipe(yaml.Lookup(field))
+		if err != nil || list == nil {
+			continue
+		}
+
+		for _, item := range list.Content() {
+			if item.Kind != yaml.ScalarNode || !strings.Contains(item.Value, "\n") {
+				continue // this item is a path, not an inline config
+			}
+
+			inline, err := yaml.Parse(item.Value)
+			if err != nil {
+				return found, err
+			}
+
+			changed, err := n.setExecFunction(inline, dir)
+			if err != nil {
+				return found, err
+			}
+
+			if changed {
+				item.Value, err = inline.String()
+				if err != nil {
+					return found, err
+				}
+
+				found = true
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// isKustomizationFile returns true if the file has one of the names kustomize
+// recognizes for a kustomization. Kustomize does not require a kind in those
+// files, so the name is what identifies them.
+func isKustomizationFile(path string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if filepath.Base(path) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSupportedKind(kind string) bool {
+	_, found := wrappers.DefaultRegistry.Lookup(kind)
+
+	return found
+}
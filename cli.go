@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/JustinKuli/policy-transformer/wrappers"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// commands are the subcommands available when running outside of kustomize.
+// When no subcommand is given, the transformer runs as a KRM function.
+var commands = map[string]func(args []string, stdout, stderr io.Writer) error{
+	"build":   RunBuild,
+	"migrate": RunMigrate,
+	"wrap":    RunWrap,
+}
+
+// stringList is a flag.Value which can be specified multiple times.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(val string) error {
+	*s = append(*s, val)
+	return nil
+}
+
+// RunWrap reads the inputs and the wrapper config specified in the args, and
+// writes the wrapped output either to stdout or to a directory. The results
+// reported by the wrappers are written to stderr.
+func RunWrap(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("wrap", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: policy-transformer wrap --config <file> [--input <path>]... [<path>...]")
+		fs.PrintDefaults()
+	}
+
+	var inputs stringList
+	fs.Var(&inputs, "input", "file or directory of manifests to wrap (can be repeated)")
+	configPath := fs.String("config", "", "path to the wrapper config file")
+	outputFormat := fs.String("output", "yaml", "output format: yaml or json")
+	outputDir := fs.String("output-dir", "", "write the output as a directory tree instead of to stdout")
+	dryRun := fs.Bool("dry-run", false, "list the objects (and files) that would be written, without writing them")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	inputs = append(inputs, fs.Args()...)
+
+	if *configPath == "" {
+		return errors.New("the --config flag is required")
+	}
+
+	if *outputFormat != "yaml" && *outputFormat != "json" {
+		return fmt.Errorf("unknown output format '%v', must be yaml or json", *outputFormat)
+	}
+
+	if *outputDir != "" && *outputFormat == "json" {
+		return errors.New("--output json can not be used with --output-dir")
+	}
+
+	cfg, err := ReadConfigFile(*configPath)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := wrappers.ReadInputs(inputs)
+	if err != nil {
+		return err
+	}
+
+	results := framework.Results{}
+
+	out, err := wrappers.PolicyTransformer{
+		Config:        cfg,
+		ManifestsRoot: filepath.Dir(*configPath),
+		Results:       &results,
+	}.Filter(nodes)
+
+	for _, result := range results {
+		fmt.Fprintln(stderr, result)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if *outputDir != "" {
+		if *dryRun {
+			return listFiles(out, stdout)
+		}
+
+		err := os.MkdirAll(*outputDir, 0o755)
+		if err != nil {
+			return err
+		}
+
+		setBlockStyle(out)
+
+		return kio.LocalPackageWriter{PackagePath: *outputDir}.Write(out)
+	}
+
+	_, err = wrappers.ClearInternalAnnotations(out)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		return listObjects(out, stdout)
+	}
+
+	if *outputFormat == "json" {
+		return writeJSONList(out, stdout)
+	}
+
+	setBlockStyle(out)
+
+	return kio.ByteWriter{Writer: stdout}.Write(out)
+}
+
+// setBlockStyle changes every map and list in the nodes to the block style, so
+// that the output is formatted the same way no matter how the objects were
+// created, which keeps diffs of it small.
+func setBlockStyle(nodes []*yaml.RNode) {
+	var visit func(node *yaml.Node)
+
+	visit = func(node *yaml.Node) {
+		if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+			node.Style &^= yaml.FlowStyle
+		}
+
+		for _, child := range node.Content {
+			visit(child)
+		}
+	}
+
+	for _, node := range nodes {
+		visit(node.YNode())
+	}
+}
+
+// ReadConfigFile reads a wrapper config from the given file.
+func ReadConfigFile(path string) (*wrappers.TransformerConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := wrappers.TransformerConfig{}
+
+	err = yaml.Unmarshal(b, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file %v: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// listObjects writes the kind and name of each object, one per line.
+func listObjects(nodes []*yaml.RNode, w io.Writer) error {
+	for _, node := range nodes {
+		_, err := fmt.Fprintf(w, "%v/%v\n", node.GetKind(), node.GetName())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listFiles writes the paths of the files that would be written, and the
+// objects in each of them.
+func listFiles(nodes []*yaml.RNode, w io.Writer) error {
+	err := kioutil.DefaultPathAndIndexAnnotation("", nodes)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string][]*yaml.RNode)
+
+	for _, node := range nodes {
+		path, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil {
+			return err
+		}
+
+		files[path] = append(files[path], node)
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		_, err := fmt.Fprintln(w, path)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range files[path] {
+			_, err := fmt.Fprintf(w, "  %v/%v\n", node.GetKind(), node.GetName())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeJSONList writes the nodes as the items of a JSON `List`.
+func writeJSONList(nodes []*yaml.RNode, w io.Writer) error {
+	items := make([]json.RawMessage, len(nodes))
+
+	for i, node := range nodes {
+		b, err := node.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		items[i] = b
+	}
+
+	list := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(list)
+}
@@ -2,96 +2,56 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 
+	"github.com/JustinKuli/policy-transformer/wrappers"
 	"sigs.k8s.io/kustomize/kyaml/fn/framework"
 	"sigs.k8s.io/kustomize/kyaml/kio"
-	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
-	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
-type PolicyTransformer struct {
-	Config *TransfomerConfig
-}
-
-type TransfomerConfig struct {
-	// ResourceMeta has APIVersion, Kind, and a subset of the k8s metadata fields
-	yaml.ResourceMeta `json:",inline" yaml:",inline"`
-
-	Spec map[string]interface{}
-}
-
-func (t PolicyTransformer) Filter(operand []*yaml.RNode) ([]*yaml.RNode, error) {
-	configSpec, err := json.Marshal(t.Config.Spec)
-	if err != nil {
-		return operand, err
-	}
-
-	var transformer kio.Filter
-
-	switch t.Config.Kind {
-	case "ConfigurationPolicyWrapper":
-		w := NewConfigurationPolicyWrapper()
-
-		err = json.Unmarshal(configSpec, &w)
-		if err != nil {
-			return operand, err
+func main() {
+	if len(os.Args) > 1 {
+		cmd, ok := commands[os.Args[1]]
+		if !ok {
+			log.Fatalf("unknown command '%v'", os.Args[1])
 		}
 
-		w.PolicyName = t.Config.Name
-
-		transformer = w
-	case "PolicyWrapper":
-		w := NewPolicyWrapper()
-
-		err = json.Unmarshal(configSpec, &w)
+		err := cmd(os.Args[2:], os.Stdout, os.Stderr)
 		if err != nil {
-			return operand, err
+			log.Fatal(err)
 		}
 
-		w.PolicyName = t.Config.Name
-
-		transformer = w
-	default:
-		return operand, fmt.Errorf("unknown PolicyTransformer kind '%v'", t.Config.Kind)
+		return
 	}
 
-	return transformer.Filter(operand)
-}
+	stdin, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func ClearInternalAnnotations(operand []*yaml.RNode) ([]*yaml.RNode, error) {
-	for _, rsrc := range operand {
-		internalAnnos := kioutil.GetInternalAnnotations(rsrc)
-		for key := range internalAnnos {
-			_, err := yaml.ClearAnnotation(key).Filter(rsrc)
-			if err != nil {
-				return operand, err
-			}
-		}
+	cfg := wrappers.TransformerConfig{}
+	results := framework.Results{}
 
-		// one more annotation that isn't in `GetInternalAnnotations`
-		_, err := yaml.ClearAnnotation("kustomize.config.k8s.io/id").Filter(rsrc)
+	proc := framework.ResourceListProcessorFunc(func(rl *framework.ResourceList) error {
+		err := framework.LoadFunctionConfig(rl.FunctionConfig, &cfg)
 		if err != nil {
-			return operand, err
+			return fmt.Errorf("loading function config: %w", err)
 		}
-	}
 
-	return operand, nil
-}
+		err = rl.Filter(wrappers.PolicyTransformer{Config: &cfg, Results: &results})
 
-func main() {
-	stdin, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		log.Fatal(err)
-	}
+		// Include the results even if there was an error, they might explain it.
+		rl.Results = append(rl.Results, results...)
 
-	cfg := TransfomerConfig{}
+		if err != nil {
+			return fmt.Errorf("processing filter: %w", err)
+		}
 
-	proc := framework.SimpleProcessor{Filter: PolicyTransformer{Config: &cfg}, Config: &cfg}
+		return nil
+	})
 
 	err = framework.Execute(proc, &kio.ByteReadWriter{
 		Reader:                bytes.NewReader(stdin),
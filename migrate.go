@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JustinKuli/policy-transformer/wrappers"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// RunMigrate updates the wrapper configs in the given files and directories to
+// the latest apiVersion of their kind. Configs inlined in the
+// generators and transformers of a kustomization are also updated. Comments
+// and the order of fields are kept, but other formatting may change.
+func RunMigrate(args []string, stdout, _ io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: policy-transformer migrate [--dry-run] <path>...")
+		flags.PrintDefaults()
+	}
+
+	dryRun := flags.Bool("dry-run", false, "list the files that would be migrated, without changing them")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("at least one file or directory to migrate is required")
+	}
+
+	files, err := findConfigFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		migrated, changed, err := MigrateYAML(content, isKustomizationFile(path))
+		if err != nil {
+			return fmt.Errorf("migrating %v: %w", path, err)
+		}
+
+		if !changed {
+			continue
+		}
+
+		if !*dryRun {
+			err = os.WriteFile(path, migrated, 0o644)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = fmt.Fprintln(stdout, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findConfigFiles returns the given files, and the yaml files in the given
+// directories. Hidden files and directories (like the `.out.yaml` files in the
+// examples) in the directories are skipped.
+func findConfigFiles(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return files, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, root)
+
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			ext := filepath.Ext(path)
+			if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return files, err
+		}
+	}
+
+	return files, nil
+}
+
+// MigrateYAML updates the wrapper configs in the yaml documents to the latest
+// apiVersion of their kind. When the content is a kustomization (as given by
+// the caller, usually from the file name, or from its kind), the configs
+// inlined in its generators and transformers are updated instead. It returns the updated yaml, and whether anything was
+// changed. When nothing was changed, the content is returned as-is.
+func MigrateYAML(content []byte, kustomization bool) ([]byte, bool, error) {
+	nodes, err := (&kio.ByteReader{
+		Reader:                bytes.NewReader(content),
+		OmitReaderAnnotations: true,
+	}).Read()
+	if err != nil {
+		return content, false, err
+	}
+
+	found := false
+
+	for _, node := range nodes {
+		var changed bool
+
+		if kustomization || node.GetKind() == "Kustomization" {
+			changed, err = migrateInlineConfigs(node)
+		} else {
+			changed, err = wrappers.MigrateConfig(node)
+		}
+
+		if err != nil {
+			return content, false, err
+		}
+
+		found = found || changed
+	}
+
+	if !found {
+		return content, false, nil
+	}
+
+	var buf bytes.Buffer
+
+	err = kio.ByteWriter{Writer: &buf}.Write(nodes)
+
+	return buf.Bytes(), true, err
+}
+
+// migrateInlineConfigs calls wrappers.MigrateConfig on the configs which are
+// inlined as strings in the generators and transformers of the kustomization.
+// It returns whether any of them were changed.
+func migrateInlineConfigs(kustomization *yaml.RNode) (bool, error) {
+	found := false
+
+	for _, field := range []string{"generators", "transformers"} {
+		list, err := kustomization.Pipe(yaml.Lookup(field))
+		if err != nil || list == nil {
+			continue
+		}
+
+		for _, item := range list.Content() {
+			if item.Kind != yaml.ScalarNode || !strings.Contains(item.Value, "\n") {
+				continue // this item is a path, not an inline config
+			}
+
+			inline, err := yaml.Parse(item.Value)
+			if err != nil {
+				return found, err
+			}
+
+			changed, err := wrappers.MigrateConfig(inline)
+			if err != nil {
+				return found, err
+			}
+
+			if changed {
+				value, err := inline.String()
+				if err != nil {
+					return found, err
+				}
+
+				// Keep the original block style, for example `|-` instead of `|`
+				if !strings.HasSuffix(item.Value, "\n") {
+					value = strings.TrimSuffix(value, "\n")
+				}
+
+				item.Value = value
+
+				found = true
+			}
+		}
+	}
+
+	return found, nil
+}
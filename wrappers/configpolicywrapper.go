@@ -0,0 +1,531 @@
+package wrappers
+
+import (
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+)
+
+type ConfigurationPolicyWrapper struct {
+	Annotations          map[string]string `json:"annotations,omitempty"`
+	ComplianceType       string            `json:"complianceType,omitempty"`
+	ConsolidateManifests bool              `json:"consolidateManifests,omitempty"`
+	DuplicateStrategy    string            `json:"duplicateStrategy,omitempty"`
+	ExistingPolicies     string            `json:"existingPolicies,omitempty"`
+	EvaluationInterval   struct {
+		Compliant    string `json:"compliant,omitempty"`
+		NonCompliant string `json:"noncompliant,omitempty"`
+	} `json:"evaluationInterval,omitempty"`
+	MergeInto              string `json:"mergeInto,omitempty"`
+	MetadataComplianceType string `json:"metadataComplianceType,omitempty"`
+	NamespaceSelector      struct {
+		Include          []string                 `json:"include,omitempty"`
+		Exclude          []string                 `json:"exclude,omitempty"`
+		MatchLabels      map[string]string        `json:"matchLabels,omitempty"`
+		MatchExpressions []map[string]interface{} `json:"matchExpressions,omitempty"`
+	} `json:"namespaceSelector,omitempty"`
+	PolicyName          string `json:"policyName"`
+	PruneObjectBehavior string `json:"pruneObjectBehavior,omitempty"`
+	RecordSources       bool   `json:"recordSources,omitempty"`
+	RemediationAction   string `json:"remediationAction,omitempty"`
+	Severity            string `json:"severity,omitempty"`
+	TargetVersion       string `json:"targetVersion,omitempty"`
+
+	InputSelection // include, exclude, and unselected
+
+	Results *framework.Results `json:"-"`
+}
+
+func init() {
+	MustRegister(KindRegistration{
+		Kind:        "ConfigurationPolicyWrapper",
+		APIVersions: []string{APIVersionV1Alpha1, APIVersionV1Beta1},
+		Convert:     convertConfigurationPolicyWrapper,
+		New: func() Config {
+			w := NewConfigurationPolicyWrapper()
+
+			return &w
+		},
+	})
+}
+
+func NewConfigurationPolicyWrapper() ConfigurationPolicyWrapper {
+	// Note: leaving things unset in the config will not overwrite these defaults
+	// with the "empty" golang values (eg not setting ConsolidateManifests will
+	// not make it act like it's false). Stuff "just works" like we'd want.
+	return ConfigurationPolicyWrapper{
+		ComplianceType:       "musthave",
+		ConsolidateManifests: true,
+		DuplicateStrategy:    DuplicateError,
+		ExistingPolicies:     ExistingPassThrough,
+		RemediationAction:    "inform",
+	}
+}
+
+// Init sets the name of the policy, unless it is empty, and where results are
+// reported.
+func (c *ConfigurationPolicyWrapper) Init(name string, results *framework.Results) {
+	if name != "" {
+		c.PolicyName = name
+	}
+
+	c.Results = results
+}
+
+func (c ConfigurationPolicyWrapper) Filter(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+	if c.PolicyName == "" {
+		return operand, errors.New("ConfigurationPolicyWrapper requires a name, from the config or policyName")
+	}
+
+	err := c.checkTarget()
+	if err != nil {
+		return operand, err
+	}
+
+	operand, unselected, err := c.Select(operand, c.Results)
+	if err != nil {
+		return operand, err
+	}
+
+	operand, existing, err := SplitExisting(operand, IsGenerated, c.isMergeTarget, c.ExistingPolicies, c.Results)
+	if err != nil {
+		return operand, err
+	}
+
+	passThrough := unselected
+	if c.Unselected == UnselectedDrop {
+		passThrough = nil
+	}
+
+	if len(operand) == 0 && len(existing) != 0 {
+		// Everything was already wrapped, so running again changes nothing.
+		return append(existing, passThrough...), nil
+	}
+
+	if c.hasNamespaceSelector() {
+		for _, rsrc := range operand {
+			if IsClusterScoped(rsrc) {
+				Report(c.Results, NewResult(framework.Info,
+					"the namespaceSelector does not apply to this cluster-scoped object",
+					rsrc, "spec.namespaceSelector"))
+			}
+		}
+	}
+
+	target := c.findMergeTarget(existing)
+
+	if c.ConsolidateManifests || target != nil {
+		operand, err = Deduplicate(operand, c.DuplicateStrategy, c.Results)
+		if err != nil {
+			return operand, err
+		}
+	}
+
+	sources := CaptureProvenance(operand)
+
+	if target != nil {
+		err = c.MergeIntoPolicy(target, operand, sources)
+		if err != nil {
+			return operand, err
+		}
+
+		return append(existing, passThrough...), nil
+	}
+
+	names, groups, err := GroupInputs(operand, c.ConsolidateManifests, c.PolicyName)
+	if err != nil {
+		return operand, err
+	}
+
+	out := make([]*yaml.RNode, len(groups))
+	merged := make(map[*yaml.RNode]bool)
+
+	for i, group := range groups {
+		policy, err := c.NewPolicy()
+		if err != nil {
+			return out, fmt.Errorf("creating ConfigurationPolicy %v for %v: %w",
+				names[i], sources.Describe(group), err)
+		}
+
+		for _, rsrc := range group {
+			wrapped, err := c.WrapResource(rsrc)
+			if err != nil {
+				return out, sources.Wrap(rsrc, err)
+			}
+
+			err = policy.PipeE(
+				yaml.LookupCreate(yaml.SequenceNode, "spec", "object-templates"),
+				yaml.Append(wrapped.YNode()),
+			)
+			if err != nil {
+				return out, sources.Wrap(rsrc, err)
+			}
+		}
+
+		err = policy.SetName(names[i])
+		if err != nil {
+			return out, err
+		}
+
+		policy, err = MergeExisting(policy, existing, merged, c.ExistingPolicies, "spec", "object-templates")
+		if err != nil {
+			return out, err
+		}
+
+		err = c.SetSources(policy, sources, group)
+		if err != nil {
+			return out, err
+		}
+
+		if len(group) == 0 {
+			Report(c.Results, NewResult(framework.Warning,
+				"the ConfigurationPolicy is empty because there were no inputs to wrap",
+				policy, "spec.object-templates"))
+		}
+
+		out[i] = policy
+	}
+
+	for _, policy := range existing {
+		if !merged[policy] {
+			out = append(out, policy)
+		}
+	}
+
+	return append(out, passThrough...), nil
+}
+
+// findMergeTarget returns the existing ConfigurationPolicy named by mergeInto,
+// or nil if mergeInto is not set or there is no such policy in the input.
+func (c ConfigurationPolicyWrapper) findMergeTarget(existing []*yaml.RNode) *yaml.RNode {
+	if c.MergeInto == "" {
+		return nil
+	}
+
+	for _, policy := range existing {
+		if c.isMergeTarget(policy) {
+			return policy
+		}
+	}
+
+	Report(c.Results, NewResult(framework.Info,
+		fmt.Sprintf("there is no ConfigurationPolicy named '%v' in the input to merge into, "+
+			"so a new policy was created", c.MergeInto),
+		nil, "mergeInto"))
+
+	return nil
+}
+
+// isMergeTarget returns true if the object is the ConfigurationPolicy named by
+// mergeInto. It is expected in the input, so existingPolicies does not apply
+// to it.
+func (c ConfigurationPolicyWrapper) isMergeTarget(obj *yaml.RNode) bool {
+	return c.MergeInto != "" && IsConfigurationPolicy(obj) && obj.GetName() == c.MergeInto
+}
+
+// MergeIntoPolicy wraps the inputs and appends them to the object-templates of
+// the target ConfigurationPolicy. Inputs which are already in the target are
+// handled according to the duplicateStrategy: either causing an error, or being
+// merged into the existing objectDefinition.
+func (c ConfigurationPolicyWrapper) MergeIntoPolicy(target *yaml.RNode, operand []*yaml.RNode, sources Sources) error {
+	templates, err := target.Pipe(yaml.LookupCreate(yaml.SequenceNode, "spec", "object-templates"))
+	if err != nil {
+		return err
+	}
+
+	// The existing objectDefinitions, by their ResourceID
+	definitions := make(map[string]*yaml.RNode)
+
+	for _, tmpl := range templates.Content() {
+		if def := yaml.NewRNode(tmpl).Field("objectDefinition"); def != nil {
+			definitions[ResourceID(def.Value)] = yaml.NewRNode(tmpl)
+		}
+	}
+
+	_, err = ClearInternalAnnotations(operand)
+	if err != nil {
+		return err
+	}
+
+	for _, rsrc := range operand {
+		id := ResourceID(rsrc)
+
+		if tmpl, found := definitions[id]; found {
+			if c.DuplicateStrategy == DuplicateError {
+				return sources.Wrap(rsrc, fmt.Errorf("the object is already in ConfigurationPolicy %v; "+
+					"set duplicateStrategy to '%v' to combine them", c.MergeInto, DuplicateMerge))
+			}
+
+			merged, err := merge2.Merge(rsrc, tmpl.Field("objectDefinition").Value, yaml.MergeOptions{
+				ListIncreaseDirection: yaml.MergeOptionsListAppend,
+			})
+			if err != nil {
+				return sources.Wrap(rsrc, fmt.Errorf("unable to merge into ConfigurationPolicy %v: %w",
+					c.MergeInto, err))
+			}
+
+			err = tmpl.PipeE(yaml.SetField("objectDefinition", merged))
+			if err != nil {
+				return sources.Wrap(rsrc, err)
+			}
+
+			Report(c.Results, NewResult(framework.Info,
+				fmt.Sprintf("merged %v into the existing template in ConfigurationPolicy %v", id, c.MergeInto),
+				target, "spec.object-templates"))
+
+			continue
+		}
+
+		wrapped, err := c.WrapResource(rsrc)
+		if err != nil {
+			return sources.Wrap(rsrc, err)
+		}
+
+		err = target.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "spec", "object-templates"),
+			yaml.Append(wrapped.YNode()),
+		)
+		if err != nil {
+			return sources.Wrap(rsrc, err)
+		}
+
+		definitions[id] = wrapped
+	}
+
+	return c.SetSources(target, sources, operand)
+}
+
+// SetSources sets the SourceAnnotation on the policy to describe the given
+// wrapped inputs, if enabled in the configuration. When the policy already
+// has the annotation, for example because the inputs were merged into an
+// existing policy, the new sources are added to it.
+func (c ConfigurationPolicyWrapper) SetSources(policy *yaml.RNode, sources Sources, wrapped []*yaml.RNode) error {
+	if !c.RecordSources || len(wrapped) == 0 {
+		return nil
+	}
+
+	description := sources.Describe(wrapped)
+	if previous := policy.GetAnnotations()[SourceAnnotation]; previous != "" {
+		description = previous + ", " + description
+	}
+
+	return policy.PipeE(yaml.SetAnnotation(SourceAnnotation, description))
+}
+
+// checkTarget returns an error if the configuration uses fields which are not
+// supported by the targetVersion.
+func (c ConfigurationPolicyWrapper) checkTarget() error {
+	target, err := LookupTarget(c.TargetVersion)
+	if err != nil {
+		return err
+	}
+
+	features := []struct {
+		feature string
+		used    bool
+	}{
+		{FeatureEvaluationInterval, c.EvaluationInterval.Compliant != "" || c.EvaluationInterval.NonCompliant != ""},
+		{FeaturePruneObjectBehavior, c.PruneObjectBehavior != ""},
+		{FeatureMetadataComplianceType, c.MetadataComplianceType != ""},
+		{
+			FeatureNamespaceSelectorLabels,
+			len(c.NamespaceSelector.MatchLabels) != 0 || len(c.NamespaceSelector.MatchExpressions) != 0,
+		},
+	}
+
+	for _, f := range features {
+		err = checkFeature(target, c.TargetVersion, f.feature, f.used, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c ConfigurationPolicyWrapper) hasNamespaceSelector() bool {
+	return len(c.NamespaceSelector.Include) != 0 ||
+		len(c.NamespaceSelector.Exclude) != 0 ||
+		len(c.NamespaceSelector.MatchLabels) != 0 ||
+		len(c.NamespaceSelector.MatchExpressions) != 0
+}
+
+// clusterScopedKinds are some common kinds which are not namespaced. Without
+// access to a cluster, this is the best that can be done to identify them.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"ClusterPolicy":                  true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"PersistentVolume":               true,
+	"PriorityClass":                  true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+}
+
+// IsClusterScoped returns true if the resource has no namespace, and is of a
+// kind which is known to be cluster-scoped.
+func IsClusterScoped(rsrc *yaml.RNode) bool {
+	return rsrc.GetNamespace() == "" && clusterScopedKinds[rsrc.GetKind()]
+}
+
+func (c ConfigurationPolicyWrapper) WrapResource(res *yaml.RNode) (*yaml.RNode, error) {
+	// The input might also be passed through, so it must not be shared with
+	// the output.
+	res = res.Copy()
+
+	LiftDocumentComments(res)
+
+	wrapped := yaml.NewMapRNode(nil)
+
+	err := wrapped.PipeE(
+		yaml.SetField("objectDefinition", res),
+	)
+	if err != nil {
+		return wrapped, err
+	}
+
+	if c.ComplianceType != "" {
+		err := wrapped.PipeE(
+			yaml.SetField("complianceType", yaml.NewScalarRNode(c.ComplianceType)),
+		)
+		if err != nil {
+			return wrapped, err
+		}
+	}
+
+	if c.MetadataComplianceType != "" {
+		err := wrapped.PipeE(
+			yaml.SetField("metadataComplianceType", yaml.NewScalarRNode(c.MetadataComplianceType)),
+		)
+		if err != nil {
+			return wrapped, err
+		}
+	}
+
+	return wrapped, nil
+}
+
+const baseConfigPolicy = `
+apiVersion: policy.open-cluster-management.io/v1
+kind: ConfigurationPolicy
+`
+
+func (c ConfigurationPolicyWrapper) NewPolicy() (*yaml.RNode, error) {
+	policy := yaml.MustParse(baseConfigPolicy)
+
+	err := policy.SetName(c.PolicyName)
+	if err != nil {
+		return policy, err
+	}
+
+	if len(c.Annotations) != 0 {
+		err := policy.SetAnnotations(c.Annotations)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if c.EvaluationInterval.Compliant != "" {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec", "evaluationInterval"),
+			yaml.SetField("compliant", yaml.NewScalarRNode(c.EvaluationInterval.Compliant)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if c.EvaluationInterval.NonCompliant != "" {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec", "evaluationInterval"),
+			yaml.SetField("noncompliant", yaml.NewScalarRNode(c.EvaluationInterval.NonCompliant)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if len(c.NamespaceSelector.Include) != 0 {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec", "namespaceSelector"),
+			yaml.SetField("include", yaml.NewListRNode(c.NamespaceSelector.Include...)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if len(c.NamespaceSelector.Exclude) != 0 {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec", "namespaceSelector"),
+			yaml.SetField("exclude", yaml.NewListRNode(c.NamespaceSelector.Exclude...)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if len(c.NamespaceSelector.MatchLabels) != 0 {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec", "namespaceSelector"),
+			yaml.SetField("matchLabels", NewSortedMapRNode(c.NamespaceSelector.MatchLabels)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	for _, exp := range c.NamespaceSelector.MatchExpressions {
+		obj, err := yaml.FromMap(exp)
+		if err != nil {
+			return policy, err
+		}
+
+		err = policy.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "spec", "namespaceSelector", "matchExpressions"),
+			yaml.Append(obj.YNode()),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if c.PruneObjectBehavior != "" {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec"),
+			yaml.SetField("pruneObjectBehavior", yaml.NewScalarRNode(c.PruneObjectBehavior)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if c.RemediationAction != "" {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec"),
+			yaml.SetField("remediationAction", yaml.NewScalarRNode(c.RemediationAction)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if c.Severity != "" {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec"),
+			yaml.SetField("severity", yaml.NewScalarRNode(c.Severity)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,250 @@
+package wrappers
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// These convert the specs of the built-in kinds from v1alpha1 to v1beta1. The
+// specs are changed in place, keeping the order of the fields and their
+// comments where possible, so that they can be used to migrate config files.
+//
+// The changes in v1beta1 are:
+//   - In a PolicyWrapper, the `clusterSelectors` and `labelSelector` maps in the
+//     placement, additionalBindings, and rollout waves are replaced by a typed
+//     `selector`, and a `kind` to create a PlacementRule instead of a Placement.
+//   - In a ConfigurationPolicyWrapper (including the `configurationPolicy`
+//     settings of a PolicyWrapper), `configurationPolicyAnnotations` is renamed
+//     to `annotations`.
+
+// MigrateConfig converts the node to the latest apiVersion of its kind, in
+// place, if it is a PolicyTransformer config with an older apiVersion. The
+// steps of a PolicyPipeline with their own older apiVersions are also
+// converted. It returns whether the node was changed.
+func MigrateConfig(node *yaml.RNode) (bool, error) {
+	kind := node.GetKind()
+	if _, found := DefaultRegistry.Lookup(kind); !found {
+		return false, nil
+	}
+
+	spec, err := node.Pipe(yaml.Lookup("spec"))
+	if err != nil {
+		return false, err
+	}
+
+	apiVersion := node.GetApiVersion()
+
+	converted, err := DefaultRegistry.Convert(apiVersion, kind, spec)
+	if err != nil {
+		return false, err
+	}
+
+	if converted {
+		err = node.PipeE(yaml.SetField("apiVersion", yaml.NewScalarRNode(DefaultRegistry.LatestVersion(kind))))
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if kind != "PolicyPipeline" || spec == nil {
+		return false, nil
+	}
+
+	// The pipeline is already the latest version, but its steps might not be.
+	before, err := spec.String()
+	if err != nil {
+		return false, err
+	}
+
+	err = convertPolicyPipeline(apiVersion, spec)
+	if err != nil {
+		return false, err
+	}
+
+	after, err := spec.String()
+
+	return before != after, err
+}
+
+// convertConfigurationPolicyWrapper converts the spec of a
+// ConfigurationPolicyWrapper to v1beta1.
+func convertConfigurationPolicyWrapper(fromVersion string, spec *yaml.RNode) error {
+	if fromVersion != APIVersionV1Alpha1 {
+		return nil
+	}
+
+	return renameField(spec, "configurationPolicyAnnotations", "annotations")
+}
+
+// convertPolicyWrapper converts the spec of a PolicyWrapper to v1beta1.
+func convertPolicyWrapper(fromVersion string, spec *yaml.RNode) error {
+	if fromVersion != APIVersionV1Alpha1 {
+		return nil
+	}
+
+	if placement := spec.Field("placement"); placement != nil {
+		if err := convertClusterSelection(placement.Value); err != nil {
+			return fmt.Errorf("placement: %w", err)
+		}
+	}
+
+	for _, path := range [][]string{{"additionalBindings"}, {"rollout", "waves"}} {
+		list, err := spec.Pipe(yaml.Lookup(path...))
+		if err != nil {
+			return err
+		}
+
+		if list == nil {
+			continue
+		}
+
+		for i, item := range list.Content() {
+			if err := convertClusterSelection(yaml.NewRNode(item)); err != nil {
+				return fmt.Errorf("%v[%v]: %w", path[len(path)-1], i, err)
+			}
+		}
+	}
+
+	if settings := spec.Field("configurationPolicy"); settings != nil {
+		return convertConfigurationPolicyWrapper(fromVersion, settings.Value)
+	}
+
+	return nil
+}
+
+// convertPolicyPipeline converts the spec of a PolicyPipeline to v1beta1. Each
+// step is converted from its own apiVersion if it has one (which is then
+// updated), or otherwise from the pipeline's apiVersion.
+func convertPolicyPipeline(fromVersion string, spec *yaml.RNode) error {
+	steps, err := spec.Pipe(yaml.Lookup("steps"))
+	if err != nil || steps == nil {
+		return err
+	}
+
+	for i, item := range steps.Content() {
+		step := yaml.NewRNode(item)
+
+		kind := yaml.GetValue(step.Field("kind").Value)
+		if kind == FilterStep || kind == PatchStep {
+			continue
+		}
+
+		version := fromVersion
+
+		apiVersion := step.Field("apiVersion")
+		if apiVersion != nil {
+			version = yaml.GetValue(apiVersion.Value)
+		}
+
+		var stepSpec *yaml.RNode
+		if field := step.Field("spec"); field != nil {
+			stepSpec = field.Value
+		}
+
+		converted, err := DefaultRegistry.Convert(version, kind, stepSpec)
+		if err != nil {
+			return fmt.Errorf("steps[%v]: %w", i, err)
+		}
+
+		if converted && apiVersion != nil {
+			apiVersion.Value.YNode().Value = DefaultRegistry.LatestVersion(kind)
+		}
+	}
+
+	return nil
+}
+
+// convertClusterSelection replaces the v1alpha1 `clusterSelectors` and
+// `labelSelector` maps with a `kind` and a typed `selector`. As in v1alpha1,
+// when clusterSelectors are set, the labelSelector is not used. An empty value
+// in the maps means the label must exist, with any value.
+func convertClusterSelection(node *yaml.RNode) error {
+	clusterSelectors := node.Field("clusterSelectors")
+	labelSelector := node.Field("labelSelector")
+
+	var selectorField *yaml.MapNode
+
+	if clusterSelectors != nil && len(clusterSelectors.Value.Content()) != 0 {
+		selectorField = clusterSelectors
+
+		if node.Field("kind") == nil {
+			// Put the kind just before the selector, rather than at the end
+			content := node.YNode().Content
+			for i := 0; i < len(content); i += 2 {
+				if content[i] == clusterSelectors.Key.YNode() {
+					kind := []*yaml.Node{
+						yaml.NewScalarRNode("kind").YNode(), yaml.NewScalarRNode("PlacementRule").YNode(),
+					}
+					node.YNode().Content = append(content[:i], append(kind, content[i:]...)...)
+
+					break
+				}
+			}
+		}
+	} else if labelSelector != nil {
+		selectorField = labelSelector
+	}
+
+	if selectorField != nil {
+		sel := make(map[string]string)
+
+		err := selectorField.Value.VisitFields(func(field *yaml.MapNode) error {
+			sel[yaml.GetValue(field.Key)] = yaml.GetValue(field.Value)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		exprs, err := BuildMatchExpressions(sel)
+		if err != nil {
+			return err
+		}
+
+		selector := yaml.NewMapRNode(nil)
+
+		exprList, err := selector.Pipe(yaml.LookupCreate(yaml.SequenceNode, "matchExpressions"))
+		if err != nil {
+			return err
+		}
+
+		for _, expr := range exprs {
+			err = exprList.PipeE(yaml.Append(expr.YNode()))
+			if err != nil {
+				return err
+			}
+		}
+
+		// Reuse the key node, to keep its position and comments
+		selectorField.Key.YNode().Value = "selector"
+		*selectorField.Value.YNode() = *selector.YNode()
+	}
+
+	for _, name := range []string{"clusterSelectors", "labelSelector"} {
+		if _, err := node.Pipe(yaml.Clear(name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renameField changes the name of the field in the map, if it is set.
+func renameField(node *yaml.RNode, from, to string) error {
+	field := node.Field(from)
+	if field == nil {
+		return nil
+	}
+
+	if node.Field(to) != nil {
+		return fmt.Errorf("both %v and %v are set, only %v should be used", from, to, to)
+	}
+
+	field.Key.YNode().Value = to
+
+	return nil
+}
@@ -0,0 +1,15 @@
+// Package wrappers implements the PolicyTransformer: kio.Filters which wrap
+// Kubernetes manifests in Open Cluster Management policies, placements, and
+// ManifestWorks.
+//
+// Each kind of config (PolicyWrapper, ConfigurationPolicyWrapper,
+// ManifestWorkWrapper, and PolicyPipeline) is a type with exported fields
+// matching its spec, registered in the DefaultRegistry. Configs can be created
+// from a kind and a spec (a map, raw JSON, or a value of the config type) with
+// New, or directly from their NewX constructors, and then used like any other
+// kio.Filter. PolicyTransformer runs a config file as a KRM function, and is
+// what the policy-transformer binary uses.
+//
+// The lower-level helpers, like Split and BuildMatchExpressions, are also
+// exported for functions which need to do their own wrapping.
+package wrappers
@@ -0,0 +1,88 @@
+package wrappers
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+)
+
+const (
+	// DuplicateError causes an error when duplicate inputs are found.
+	DuplicateError = "error"
+	// DuplicateMerge merges later duplicates into the first one found.
+	DuplicateMerge = "merge"
+)
+
+// ResourceID returns an identifier for the resource, made from its apiVersion,
+// kind, namespace, and name.
+func ResourceID(rsrc *yaml.RNode) string {
+	id := rsrc.GetApiVersion() + "/" + rsrc.GetKind() + "/"
+
+	if ns := rsrc.GetNamespace(); ns != "" {
+		id += ns + "/"
+	}
+
+	return id + rsrc.GetName()
+}
+
+// SourceOf returns a description of where the resource came from, based on its
+// Provenance. If that is not known, its position in the input is used.
+func SourceOf(rsrc *yaml.RNode, position int) string {
+	if source := GetProvenance(rsrc).Source(); source != "" {
+		return source
+	}
+
+	return fmt.Sprintf("input %v", position)
+}
+
+// Deduplicate finds inputs with the same apiVersion, kind, namespace, and name,
+// and handles them according to the strategy. It must be called before the
+// internal annotations are cleared from the inputs, so that the sources of the
+// duplicates can be reported.
+func Deduplicate(operand []*yaml.RNode, strategy string, results *framework.Results) ([]*yaml.RNode, error) {
+	if strategy != DuplicateError && strategy != DuplicateMerge {
+		return operand, fmt.Errorf("unknown duplicateStrategy '%v', must be '%v' or '%v'",
+			strategy, DuplicateError, DuplicateMerge)
+	}
+
+	out := make([]*yaml.RNode, 0, len(operand))
+	firstSeen := make(map[string]int) // the index in `out`
+	sources := make(map[string]string)
+
+	for i, rsrc := range operand {
+		id := ResourceID(rsrc)
+
+		idx, found := firstSeen[id]
+		if !found {
+			firstSeen[id] = len(out)
+			sources[id] = SourceOf(rsrc, i)
+			out = append(out, rsrc)
+
+			continue
+		}
+
+		if strategy == DuplicateError {
+			return operand, fmt.Errorf("duplicate object %v found in %v and %v; "+
+				"set duplicateStrategy to '%v' to combine them",
+				id, sources[id], SourceOf(rsrc, i), DuplicateMerge)
+		}
+
+		merged, err := merge2.Merge(rsrc, out[idx], yaml.MergeOptions{
+			ListIncreaseDirection: yaml.MergeOptionsListAppend,
+		})
+		if err != nil {
+			return operand, fmt.Errorf("unable to merge duplicate object %v from %v into %v: %w",
+				id, SourceOf(rsrc, i), sources[id], err)
+		}
+
+		Report(results, NewResult(framework.Info,
+			fmt.Sprintf("merged duplicate object from %v into %v", SourceOf(rsrc, i), sources[id]),
+			rsrc, ""))
+
+		out[idx] = merged
+	}
+
+	return out, nil
+}
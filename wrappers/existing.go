@@ -0,0 +1,124 @@
+package wrappers
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// ExistingPassThrough emits inputs which are already the wrapper's output
+	// kind unchanged, instead of wrapping them again.
+	ExistingPassThrough = "passThrough"
+	// ExistingMerge adds the newly wrapped templates to an existing policy with
+	// the same name, and otherwise acts like ExistingPassThrough.
+	ExistingMerge = "merge"
+	// ExistingError causes an error when an input is already the wrapper's
+	// output kind.
+	ExistingError = "error"
+)
+
+// IsConfigurationPolicy returns true if the object is a ConfigurationPolicy.
+func IsConfigurationPolicy(obj *yaml.RNode) bool {
+	return obj.GetApiVersion() == "policy.open-cluster-management.io/v1" && obj.GetKind() == "ConfigurationPolicy"
+}
+
+// IsPolicy returns true if the object is a Policy (and not another kind which
+// ends in "Policy").
+func IsPolicy(obj *yaml.RNode) bool {
+	return obj.GetApiVersion() == "policy.open-cluster-management.io/v1" && obj.GetKind() == "Policy"
+}
+
+// IsGenerated returns true if the object is one of the kinds generated by the
+// policy wrappers: a ConfigurationPolicy, Policy, PolicyAutomation, Placement,
+// PlacementRule, or PlacementBinding. Wrapping these in a ConfigurationPolicy
+// is almost certainly a mistake, for example from running a kustomization which
+// uses both wrappers on its own output.
+func IsGenerated(obj *yaml.RNode) bool {
+	if IsConfigurationPolicy(obj) || IsPolicy(obj) || IsPlacement(obj) || IsPlacementBinding(obj) {
+		return true
+	}
+
+	return obj.GetApiVersion() == "policy.open-cluster-management.io/v1beta1" && obj.GetKind() == "PolicyAutomation"
+}
+
+// SplitExisting separates the inputs which were already wrapped, as identified
+// by isOutput, from the ones which should be wrapped. When the strategy is
+// ExistingError, wrapped inputs are an error unless exempt (which may be nil)
+// returns true for them, like the policy named by mergeInto.
+func SplitExisting(
+	operand []*yaml.RNode, isOutput, exempt func(*yaml.RNode) bool, strategy string, results *framework.Results,
+) (inputs, existing []*yaml.RNode, err error) {
+	if strategy != ExistingPassThrough && strategy != ExistingMerge && strategy != ExistingError {
+		return operand, nil, fmt.Errorf("unknown existingPolicies '%v', must be '%v', '%v', or '%v'",
+			strategy, ExistingPassThrough, ExistingMerge, ExistingError)
+	}
+
+	inputs = make([]*yaml.RNode, 0, len(operand))
+	existing = make([]*yaml.RNode, 0)
+
+	for i, rsrc := range operand {
+		if !isOutput(rsrc) {
+			inputs = append(inputs, rsrc)
+
+			continue
+		}
+
+		if strategy == ExistingError && (exempt == nil || !exempt(rsrc)) {
+			return operand, nil, fmt.Errorf("%v in %v is already wrapped; set existingPolicies to '%v' or '%v' "+
+				"to allow it", ResourceID(rsrc), SourceOf(rsrc, i), ExistingPassThrough, ExistingMerge)
+		}
+
+		Report(results, NewResult(framework.Info,
+			fmt.Sprintf("not wrapped because it is already a %v", rsrc.GetKind()), rsrc, ""))
+
+		existing = append(existing, rsrc)
+	}
+
+	return inputs, existing, nil
+}
+
+// MergeExisting appends the items in the list at the given path in the
+// generated policy to the same list in the existing policy with the same kind
+// and name, and returns that existing policy. If there is no such policy, the
+// generated policy is returned. When there is one, but the strategy is not
+// ExistingMerge, an error is returned, so that two objects with the same name
+// are never emitted. Merged policies are recorded in the used map.
+func MergeExisting(
+	generated *yaml.RNode, existing []*yaml.RNode, used map[*yaml.RNode]bool, strategy string, path ...string,
+) (*yaml.RNode, error) {
+	for _, policy := range existing {
+		if used[policy] || policy.GetName() != generated.GetName() || policy.GetKind() != generated.GetKind() {
+			continue
+		}
+
+		if strategy != ExistingMerge {
+			return generated, fmt.Errorf("the generated %v has the same name as an existing one in the input; "+
+				"set existingPolicies to '%v' to add to it, or use a different name", ResourceID(generated), ExistingMerge)
+		}
+
+		items, err := generated.Pipe(yaml.Lookup(path...))
+		if err != nil {
+			return generated, err
+		}
+
+		if items != nil {
+			for _, item := range items.Content() {
+				err = policy.PipeE(
+					yaml.LookupCreate(yaml.SequenceNode, path...),
+					yaml.Append(item),
+				)
+				if err != nil {
+					return generated, fmt.Errorf("merging into existing %v: %w", ResourceID(policy), err)
+				}
+			}
+		}
+
+		used[policy] = true
+
+		return policy, nil
+	}
+
+	return generated, nil
+}
@@ -0,0 +1,157 @@
+package wrappers
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// LayoutFilePerPolicy puts each generated policy in its own file, along
+	// with the PolicyAutomation, Placement and PlacementBinding for it.
+	LayoutFilePerPolicy = "filePerPolicy"
+	// LayoutDirPerNamespace is like LayoutFilePerPolicy, but the files are put
+	// in a directory named after the namespace of the policy.
+	LayoutDirPerNamespace = "dirPerNamespace"
+)
+
+// SetOutputPaths sets the path and index annotations on the generated objects
+// according to the layout, so that tools like `kustomize fn run` and kpt will
+// write them to organized files. Only the objects created by the wrapper should
+// be given, see CreatedObjects; inputs which are passed through keep their
+// paths. An empty layout leaves the paths up to the framework.
+func SetOutputPaths(generated []*yaml.RNode, layout string) error {
+	if layout == "" {
+		return nil
+	}
+
+	if layout != LayoutFilePerPolicy && layout != LayoutDirPerNamespace {
+		return fmt.Errorf("unknown outputLayout '%v', must be '%v' or '%v'",
+			layout, LayoutFilePerPolicy, LayoutDirPerNamespace)
+	}
+
+	files := make(map[*yaml.RNode]string, len(generated))
+	policyFiles := make(map[string]string)  // policy names to their files
+	bindingFiles := make(map[string]string) // placement names to their binding's files
+
+	fileFor := func(node *yaml.RNode) string {
+		name := strings.ToLower(node.GetKind()) + "_" + node.GetName() + ".yaml"
+
+		if layout == LayoutDirPerNamespace && node.GetNamespace() != "" {
+			return path.Join(node.GetNamespace(), name)
+		}
+
+		return name
+	}
+
+	// Policies get their own files, which their automations, bindings, and
+	// placements join.
+	for _, node := range generated {
+		if node.GetKind() == "Policy" {
+			files[node] = fileFor(node)
+			policyFiles[node.GetName()] = files[node]
+		}
+	}
+
+	for _, node := range generated {
+		if node.GetKind() != "PlacementBinding" {
+			continue
+		}
+
+		files[node] = fileFor(node)
+
+		subjects, err := node.Pipe(yaml.Lookup("subjects"))
+		if err == nil && subjects != nil {
+			for _, subject := range subjects.Content() {
+				name := yaml.NewRNode(subject).Field("name")
+				if name == nil {
+					continue
+				}
+
+				if policyFile, found := policyFiles[yaml.GetValue(name.Value)]; found {
+					files[node] = policyFile
+
+					break
+				}
+			}
+		}
+
+		placementName, err := node.Pipe(yaml.Lookup("placementRef", "name"))
+		if err == nil && placementName != nil {
+			if _, found := bindingFiles[yaml.GetValue(placementName)]; !found {
+				bindingFiles[yaml.GetValue(placementName)] = files[node]
+			}
+		}
+	}
+
+	for _, node := range generated {
+		if _, found := files[node]; found {
+			continue
+		}
+
+		files[node] = fileFor(node)
+
+		if node.GetKind() == "PolicyAutomation" {
+			policyRef, err := node.Pipe(yaml.Lookup("spec", "policyRef"))
+			if err == nil && policyRef != nil {
+				if policyFile, found := policyFiles[yaml.GetValue(policyRef)]; found {
+					files[node] = policyFile
+				}
+			}
+		}
+
+		if IsPlacement(node) {
+			if bindingFile, found := bindingFiles[node.GetName()]; found {
+				files[node] = bindingFile
+			}
+		}
+	}
+
+	// Set the annotations in the output order, so the indexes are in order.
+	indexes := make(map[string]int)
+
+	for _, node := range generated {
+		file := files[node]
+
+		err := node.PipeE(yaml.SetAnnotation(kioutil.PathAnnotation, file))
+		if err != nil {
+			return err
+		}
+
+		err = node.PipeE(yaml.SetAnnotation(kioutil.IndexAnnotation, strconv.Itoa(indexes[file])))
+		if err != nil {
+			return err
+		}
+
+		indexes[file]++
+	}
+
+	return nil
+}
+
+// CreatedObjects returns the objects in the output which are not one of the
+// inputs, in their output order. Inputs which were changed in place, like an
+// existing policy which was merged into, are not included.
+func CreatedObjects(output []*yaml.RNode, inputs ...[]*yaml.RNode) []*yaml.RNode {
+	isInput := make(map[*yaml.Node]bool)
+
+	for _, list := range inputs {
+		for _, node := range list {
+			isInput[node.YNode()] = true
+		}
+	}
+
+	created := make([]*yaml.RNode, 0, len(output))
+
+	for _, node := range output {
+		if !isInput[node.YNode()] {
+			created = append(created, node)
+		}
+	}
+
+	return created
+}
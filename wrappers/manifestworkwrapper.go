@@ -0,0 +1,240 @@
+package wrappers
+
+import (
+	"errors"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+type ManifestWorkWrapper struct {
+	ClusterNamespaces    []string `json:"clusterNamespaces,omitempty"`
+	ConsolidateManifests bool     `json:"consolidateManifests,omitempty"`
+	DeleteOption         struct {
+		PropagationPolicy string                   `json:"propagationPolicy,omitempty"`
+		OrphaningRules    []map[string]interface{} `json:"orphaningRules,omitempty"`
+	} `json:"deleteOption,omitempty"`
+	ManifestConfigs []map[string]interface{} `json:"manifestConfigs,omitempty"`
+	Namespace       string                   `json:"namespace,omitempty"` // For the ManifestWorkReplicaSet
+	PlacementRefs   []string                 `json:"placementRefs,omitempty"`
+	WorkName        string                   `json:"workName"`
+
+	Results *framework.Results `json:"-"`
+}
+
+func init() {
+	MustRegister(KindRegistration{
+		Kind:        "ManifestWorkWrapper",
+		APIVersions: []string{APIVersionV1Alpha1, APIVersionV1Beta1},
+		New: func() Config {
+			w := NewManifestWorkWrapper()
+
+			return &w
+		},
+	})
+}
+
+// NewManifestWorkWrapper returns a new ManifestWorkWrapper with some defaults
+// set.
+func NewManifestWorkWrapper() ManifestWorkWrapper {
+	return ManifestWorkWrapper{
+		ConsolidateManifests: true,
+	}
+}
+
+// Init sets the name of the ManifestWork, unless it is empty, and where results
+// are reported.
+func (c *ManifestWorkWrapper) Init(name string, results *framework.Results) {
+	if name != "" {
+		c.WorkName = name
+	}
+
+	c.Results = results
+}
+
+// Filter wraps the given inputs into a ManifestWork for each of the configured
+// cluster namespaces, and into a ManifestWorkReplicaSet if any placements are
+// configured. Inputs are grouped the same way as in the
+// ConfigurationPolicyWrapper.
+func (c ManifestWorkWrapper) Filter(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+	if len(c.ClusterNamespaces) == 0 && len(c.PlacementRefs) == 0 {
+		return operand, errors.New("ManifestWorkWrapper requires clusterNamespaces or placementRefs to be set")
+	}
+
+	if c.WorkName == "" {
+		return operand, errors.New("ManifestWorkWrapper requires a name, from the config or workName")
+	}
+
+	names, groups, err := GroupInputs(operand, c.ConsolidateManifests, c.WorkName)
+	if err != nil {
+		return operand, err
+	}
+
+	out := make([]*yaml.RNode, 0)
+
+	for i, group := range groups {
+		for _, clusterNS := range c.ClusterNamespaces {
+			work, err := c.NewManifestWork(names[i], clusterNS, group)
+			if err != nil {
+				return out, err
+			}
+
+			out = append(out, work)
+		}
+
+		if len(c.PlacementRefs) != 0 {
+			replicaSet, err := c.NewManifestWorkReplicaSet(names[i], group)
+			if err != nil {
+				return out, err
+			}
+
+			out = append(out, replicaSet)
+		}
+	}
+
+	return out, nil
+}
+
+const baseManifestWork = `
+apiVersion: work.open-cluster-management.io/v1
+kind: ManifestWork
+`
+
+// NewManifestWork returns a ManifestWork in the given cluster namespace, which
+// will deliver the given manifests to that cluster.
+func (c ManifestWorkWrapper) NewManifestWork(name, clusterNS string, manifests []*yaml.RNode) (*yaml.RNode, error) {
+	work := yaml.MustParse(baseManifestWork)
+
+	err := work.SetName(name)
+	if err != nil {
+		return work, err
+	}
+
+	err = work.SetNamespace(clusterNS)
+	if err != nil {
+		return work, err
+	}
+
+	spec, err := c.NewWorkSpec(manifests)
+	if err != nil {
+		return work, err
+	}
+
+	err = work.PipeE(yaml.SetField("spec", spec))
+
+	return work, err
+}
+
+const baseManifestWorkReplicaSet = `
+apiVersion: work.open-cluster-management.io/v1alpha1
+kind: ManifestWorkReplicaSet
+`
+
+// NewManifestWorkReplicaSet returns a ManifestWorkReplicaSet which will deliver
+// the given manifests to the clusters selected by the configured placements.
+func (c ManifestWorkWrapper) NewManifestWorkReplicaSet(name string, manifests []*yaml.RNode) (*yaml.RNode, error) {
+	replicaSet := yaml.MustParse(baseManifestWorkReplicaSet)
+
+	err := replicaSet.SetName(name)
+	if err != nil {
+		return replicaSet, err
+	}
+
+	if c.Namespace != "" {
+		err := replicaSet.SetNamespace(c.Namespace)
+		if err != nil {
+			return replicaSet, err
+		}
+	}
+
+	for _, placementName := range c.PlacementRefs {
+		ref := yaml.NewMapRNode(&map[string]string{"name": placementName})
+
+		err = replicaSet.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "spec", "placementRefs"),
+			yaml.Append(ref.YNode()),
+		)
+		if err != nil {
+			return replicaSet, err
+		}
+	}
+
+	spec, err := c.NewWorkSpec(manifests)
+	if err != nil {
+		return replicaSet, err
+	}
+
+	err = replicaSet.PipeE(
+		yaml.LookupCreate(yaml.MappingNode, "spec"),
+		yaml.SetField("manifestWorkTemplate", spec),
+	)
+
+	return replicaSet, err
+}
+
+// NewWorkSpec returns the spec of a ManifestWork containing the given manifests,
+// with the configured deleteOption and manifestConfigs.
+func (c ManifestWorkWrapper) NewWorkSpec(manifests []*yaml.RNode) (*yaml.RNode, error) {
+	spec := yaml.NewMapRNode(nil)
+
+	err := spec.PipeE(
+		yaml.LookupCreate(yaml.SequenceNode, "workload", "manifests"),
+	)
+	if err != nil {
+		return spec, err
+	}
+
+	for _, manifest := range manifests {
+		LiftDocumentComments(manifest)
+
+		err := spec.PipeE(
+			yaml.Lookup("workload", "manifests"),
+			yaml.Append(manifest.Copy().YNode()),
+		)
+		if err != nil {
+			return spec, err
+		}
+	}
+
+	if c.DeleteOption.PropagationPolicy != "" {
+		err := spec.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "deleteOption"),
+			yaml.SetField("propagationPolicy", yaml.NewScalarRNode(c.DeleteOption.PropagationPolicy)),
+		)
+		if err != nil {
+			return spec, err
+		}
+	}
+
+	for _, rule := range c.DeleteOption.OrphaningRules {
+		obj, err := yaml.FromMap(rule)
+		if err != nil {
+			return spec, err
+		}
+
+		err = spec.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "deleteOption", "selectivelyOrphans", "orphaningRules"),
+			yaml.Append(obj.YNode()),
+		)
+		if err != nil {
+			return spec, err
+		}
+	}
+
+	for _, config := range c.ManifestConfigs {
+		obj, err := yaml.FromMap(config)
+		if err != nil {
+			return spec, err
+		}
+
+		err = spec.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "manifestConfigs"),
+			yaml.Append(obj.YNode()),
+		)
+		if err != nil {
+			return spec, err
+		}
+	}
+
+	return spec, nil
+}
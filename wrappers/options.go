@@ -0,0 +1,145 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+)
+
+// Option configures a config created with New.
+type Option func(*options)
+
+type options struct {
+	apiVersion string
+	name       string
+	spec       interface{}
+	results    *framework.Results
+}
+
+// WithAPIVersion sets the apiVersion of the spec. By default, the spec is for
+// the latest apiVersion of the kind; specs for older ones are converted.
+func WithAPIVersion(apiVersion string) Option {
+	return func(o *options) {
+		o.apiVersion = apiVersion
+	}
+}
+
+// WithName sets the name of the config, like the metadata.name of a config
+// file. It is the default name for the objects the config generates.
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithSpec sets the spec of the config. It can be a map or raw JSON, which is
+// checked and decoded like the spec in a config file, so fields which are not
+// in it keep their defaults. It can also be a value of the kind's config type
+// (or a pointer to one; start from its NewX constructor to keep the defaults),
+// which is used as-is, and must be for the latest apiVersion.
+func WithSpec(spec interface{}) Option {
+	return func(o *options) {
+		o.spec = spec
+	}
+}
+
+// WithResults sets where the config reports its warnings and info. By default,
+// they are discarded.
+func WithResults(results *framework.Results) Option {
+	return func(o *options) {
+		o.results = results
+	}
+}
+
+// New returns a config of the given kind from the DefaultRegistry, set up by
+// the options. The config is a kio.Filter, so it can be used in a kio.Pipeline
+// or any other kyaml function. For example:
+//
+//	filter, err := wrappers.New("PolicyWrapper",
+//		wrappers.WithName("my-policy"),
+//		wrappers.WithSpec(map[string]interface{}{"wrapNonPolicies": true}),
+//	)
+func New(kind string, opts ...Option) (Config, error) {
+	return DefaultRegistry.New(kind, opts...)
+}
+
+// New returns a config of the given kind, set up by the options.
+func (r *Registry) New(kind string, opts ...Option) (Config, error) {
+	o := options{}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	config, typed, err := r.typedConfig(kind, o)
+	if err != nil || typed {
+		return config, err
+	}
+
+	var configSpec []byte
+
+	switch spec := o.spec.(type) {
+	case json.RawMessage:
+		configSpec = spec
+	case []byte:
+		configSpec = spec
+	default:
+		configSpec, err = json.Marshal(spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r.NewFilter(o.apiVersion, kind, o.name, configSpec, o.results)
+}
+
+// typedConfig returns a copy of the spec set up by the options, if the spec is
+// of the kind's config type. It is not encoded and decoded like other specs,
+// because the fields which are omitted when empty would lose values like an
+// explicit false, and go back to their defaults.
+func (r *Registry) typedConfig(kind string, o options) (Config, bool, error) {
+	if o.spec == nil {
+		return nil, false, nil
+	}
+
+	reg, err := r.lookupVersion(o.apiVersion, kind)
+	if err != nil {
+		return nil, false, err
+	}
+
+	configType := reflect.TypeOf(reg.New())
+	if configType.Kind() != reflect.Ptr {
+		return nil, false, nil
+	}
+
+	spec := reflect.ValueOf(o.spec)
+	if spec.Kind() == reflect.Ptr && spec.IsNil() {
+		return nil, false, fmt.Errorf("the spec for %v is a nil %v", kind, spec.Type())
+	}
+
+	if spec.Type() == configType {
+		spec = spec.Elem()
+	} else if spec.Type() != configType.Elem() {
+		if spec.Kind() == reflect.Struct || spec.Kind() == reflect.Ptr {
+			return nil, false, fmt.Errorf("the spec for %v must be a map, raw JSON, or a %v, not a %v",
+				kind, configType.Elem(), spec.Type())
+		}
+
+		return nil, false, nil
+	}
+
+	if o.apiVersion != "" && o.apiVersion != r.LatestVersion(kind) {
+		return nil, false, fmt.Errorf("a %v spec is for the latest apiVersion, %v, not %v",
+			configType.Elem(), r.LatestVersion(kind), o.apiVersion)
+	}
+
+	copied := reflect.New(configType.Elem())
+	copied.Elem().Set(spec)
+
+	config, _ := copied.Interface().(Config)
+	config.Init(o.name, o.results)
+
+	return config, true, nil
+}
@@ -0,0 +1,206 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+)
+
+const (
+	// FilterStep is a pipeline step which keeps only the selected resources.
+	FilterStep = "Filter"
+	// PatchStep is a pipeline step which merges a patch into the selected
+	// resources.
+	PatchStep = "Patch"
+)
+
+// PolicyPipeline runs a list of steps in order, with the output of each step
+// used as the input to the next. This allows one config to define a whole chain
+// of wrappers, for example a ConfigurationPolicyWrapper followed by a
+// PolicyWrapper.
+type PolicyPipeline struct {
+	Steps         []PipelineStep `json:"steps"`
+	TargetVersion string         `json:"targetVersion,omitempty"` // the default for the steps
+
+	Name    string             `json:"-"`
+	Results *framework.Results `json:"-"`
+}
+
+func init() {
+	MustRegister(KindRegistration{
+		Kind:        "PolicyPipeline",
+		APIVersions: []string{APIVersionV1Alpha1, APIVersionV1Beta1},
+		Convert:     convertPolicyPipeline,
+		New: func() Config {
+			return &PolicyPipeline{}
+		},
+	})
+}
+
+// PipelineStep is one step in a PolicyPipeline. The kind can be any of the kinds
+// in the DefaultRegistry, or FilterStep or PatchStep. The apiVersion is
+// optional. The name is used as the name of the wrapper config, and defaults to
+// the name of the pipeline.
+type PipelineStep struct {
+	APIVersion string                 `json:"apiVersion,omitempty"`
+	Kind       string                 `json:"kind"`
+	Name       string                 `json:"name,omitempty"`
+	Spec       map[string]interface{} `json:"spec,omitempty"`
+}
+
+// FilterStepSpec selects the resources which are kept by a FilterStep. All
+// other resources are dropped.
+type FilterStepSpec struct {
+	Include []ResourceSelector `json:"include,omitempty"`
+	Exclude []ResourceSelector `json:"exclude,omitempty"`
+}
+
+// PatchStepSpec configures a PatchStep. The patch is merged into each resource
+// matching any of the targets, or into every resource if there are no targets,
+// like a kustomize strategic merge patch.
+type PatchStepSpec struct {
+	Targets []ResourceSelector     `json:"targets,omitempty"`
+	Patch   map[string]interface{} `json:"patch"`
+}
+
+// Init sets the name of the pipeline, unless it is empty, and where results are
+// reported.
+func (p *PolicyPipeline) Init(name string, results *framework.Results) {
+	if name != "" {
+		p.Name = name
+	}
+
+	p.Results = results
+}
+
+// Filter runs each step of the pipeline in order.
+func (p PolicyPipeline) Filter(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+	if len(p.Steps) == 0 {
+		return operand, fmt.Errorf("the PolicyPipeline %v has no steps", p.Name)
+	}
+
+	for i, step := range p.Steps {
+		filter, err := p.newStepFilter(step)
+		if err != nil {
+			return operand, fmt.Errorf("creating step %v (%v): %w", i, step.Kind, err)
+		}
+
+		operand, err = filter.Filter(operand)
+		if err != nil {
+			return operand, fmt.Errorf("running step %v (%v): %w", i, step.Kind, err)
+		}
+	}
+
+	return operand, nil
+}
+
+// newStepFilter returns the filter for the step.
+func (p PolicyPipeline) newStepFilter(step PipelineStep) (kio.Filter, error) {
+	stepSpec, err := json.Marshal(step.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch step.Kind {
+	case FilterStep:
+		var spec FilterStepSpec
+
+		err = json.Unmarshal(stepSpec, &spec)
+		if err != nil {
+			return nil, err
+		}
+
+		selection := InputSelection{Include: spec.Include, Exclude: spec.Exclude, Unselected: UnselectedDrop}
+
+		return kio.FilterFunc(func(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+			selected, _, err := selection.Select(operand, p.Results)
+
+			return selected, err
+		}), nil
+	case PatchStep:
+		var spec PatchStepSpec
+
+		err = json.Unmarshal(stepSpec, &spec)
+		if err != nil {
+			return nil, err
+		}
+
+		return spec, nil
+	}
+
+	// Only the PolicyTransformer handles the common fields, so they would be
+	// silently ignored in a step.
+	common := make([]string, 0)
+
+	for field := range SchemaFor(reflect.TypeOf(CommonSpec{})).Properties {
+		if _, found := step.Spec[field]; found {
+			common = append(common, field)
+		}
+	}
+
+	if len(common) != 0 {
+		sort.Strings(common)
+
+		return nil, fmt.Errorf("%v can not be set in a step, only in the PolicyPipeline's spec", common)
+	}
+
+	name := step.Name
+	if name == "" {
+		name = p.Name
+	}
+
+	if _, found := step.Spec["targetVersion"]; !found && p.TargetVersion != "" {
+		spec := map[string]interface{}{"targetVersion": p.TargetVersion}
+		for key, val := range step.Spec {
+			spec[key] = val
+		}
+
+		stepSpec, err = json.Marshal(spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return DefaultRegistry.NewFilter(step.APIVersion, step.Kind, name, stepSpec, p.Results)
+}
+
+// Filter merges the patch into each of the targeted resources.
+func (s PatchStepSpec) Filter(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+	if len(s.Patch) == 0 {
+		return operand, fmt.Errorf("a patch is required")
+	}
+
+	selection := InputSelection{Include: s.Targets}
+
+	for i, rsrc := range operand {
+		selected, err := selection.Selected(rsrc)
+		if err != nil {
+			return operand, err
+		}
+
+		if !selected {
+			continue
+		}
+
+		// The patch is parsed each time, since merging can modify it.
+		patch, err := yaml.FromMap(s.Patch)
+		if err != nil {
+			return operand, fmt.Errorf("unable to use the patch: %w", err)
+		}
+
+		patched, err := merge2.Merge(patch, rsrc, yaml.MergeOptions{})
+		if err != nil {
+			return operand, fmt.Errorf("unable to patch %v: %w", ResourceID(rsrc), err)
+		}
+
+		operand[i] = patched
+	}
+
+	return operand, nil
+}
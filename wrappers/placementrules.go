@@ -0,0 +1,255 @@
+package wrappers
+
+import (
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// basePlacementTolerations let a Placement select clusters which are
+// unreachable or unavailable, like a PlacementRule without clusterConditions.
+const basePlacementTolerations = `
+- key: cluster.open-cluster-management.io/unreachable
+  operator: Exists
+- key: cluster.open-cluster-management.io/unavailable
+  operator: Exists
+`
+
+// ConvertPlacementRules changes each PlacementRule in the operand into an
+// equivalent Placement with the given apiVersion, in place, and updates the
+// PlacementBindings in the operand which refer to them. Anything which can not
+// be converted exactly is reported as a warning.
+func ConvertPlacementRules(operand []*yaml.RNode, placementAPIVersion string, results *framework.Results) error {
+	converted := make(map[string]bool)
+
+	for _, rsrc := range operand {
+		if !IsPlacement(rsrc) || rsrc.GetKind() != "PlacementRule" {
+			continue
+		}
+
+		err := ConvertPlacementRule(rsrc, placementAPIVersion, results)
+		if err != nil {
+			return fmt.Errorf("converting %v: %w", ResourceID(rsrc), err)
+		}
+
+		converted[rsrc.GetNamespace()+"/"+rsrc.GetName()] = true
+	}
+
+	if len(converted) == 0 {
+		return nil
+	}
+
+	for _, binding := range operand {
+		if !IsPlacementBinding(binding) {
+			continue
+		}
+
+		ref, err := binding.Pipe(yaml.Lookup("placementRef"))
+		if err != nil || ref == nil {
+			continue
+		}
+
+		kind, _ := ref.Pipe(yaml.Lookup("kind"))
+		name, _ := ref.Pipe(yaml.Lookup("name"))
+
+		if yaml.GetValue(kind) != "PlacementRule" || !converted[binding.GetNamespace()+"/"+yaml.GetValue(name)] {
+			continue
+		}
+
+		err = ref.PipeE(
+			yaml.Tee(yaml.SetField("kind", yaml.NewScalarRNode("Placement"))),
+			yaml.Tee(yaml.SetField("apiGroup", yaml.NewScalarRNode("cluster.open-cluster-management.io"))),
+		)
+		if err != nil {
+			return fmt.Errorf("updating %v: %w", ResourceID(binding), err)
+		}
+	}
+
+	return nil
+}
+
+// ConvertPlacementRule changes the PlacementRule into a Placement with the
+// given apiVersion, in place. Its metadata is kept, and its spec is converted:
+//   - the clusterSelector becomes the labelSelector of a predicate, and any
+//     listed clusters are selected by their `name` label;
+//   - clusterReplicas becomes numberOfClusters;
+//   - without clusterConditions (or with other conditions than the cluster
+//     being available), tolerations are added so that unreachable and
+//     unavailable clusters are still selected.
+//
+// The status is removed, and the other fields are dropped with a warning.
+func ConvertPlacementRule(rule *yaml.RNode, placementAPIVersion string, results *framework.Results) error {
+	spec := rule.Field("spec")
+	if spec == nil {
+		spec = &yaml.MapNode{Value: yaml.NewMapRNode(nil)}
+	}
+
+	placementSpec := yaml.NewMapRNode(nil)
+
+	predicates, err := convertClusterSelector(rule, spec.Value, results)
+	if err != nil {
+		return err
+	}
+
+	err = placementSpec.PipeE(yaml.SetField("predicates", predicates))
+	if err != nil {
+		return err
+	}
+
+	if replicas := spec.Value.Field("clusterReplicas"); replicas != nil {
+		err = placementSpec.PipeE(yaml.SetField("numberOfClusters", replicas.Value))
+		if err != nil {
+			return err
+		}
+
+		Report(results, NewResult(framework.Warning,
+			"clusterReplicas was converted to numberOfClusters, but the Placement might choose different clusters",
+			rule, "spec.clusterReplicas"))
+	}
+
+	if tolerateUnavailable(rule, spec.Value, results) {
+		err = placementSpec.PipeE(yaml.SetField("tolerations", yaml.MustParse(basePlacementTolerations)))
+		if err != nil {
+			return err
+		}
+	}
+
+	dropped := make([]string, 0)
+
+	err = spec.Value.VisitFields(func(field *yaml.MapNode) error {
+		switch key := yaml.GetValue(field.Key); key {
+		case "clusterSelector", "clusters", "clusterReplicas", "clusterConditions":
+		default:
+			dropped = append(dropped, key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(dropped) != 0 {
+		sort.Strings(dropped)
+
+		Report(results, NewResult(framework.Warning,
+			fmt.Sprintf("these fields have no equivalent in a Placement, and were dropped: %v", dropped),
+			rule, "spec"))
+	}
+
+	Report(results, NewResult(framework.Info,
+		"converted to a Placement, which only selects clusters from the ManagedClusterSets bound to its "+
+			"namespace with a ManagedClusterSetBinding", rule, ""))
+
+	rule.SetApiVersion(placementAPIVersion)
+	rule.SetKind("Placement")
+
+	_, err = rule.Pipe(yaml.Clear("status"))
+	if err != nil {
+		return err
+	}
+
+	return rule.PipeE(yaml.SetField("spec", placementSpec))
+}
+
+// convertClusterSelector returns the predicates for a Placement which select the
+// same clusters as the clusterSelector and clusters of the PlacementRule spec.
+func convertClusterSelector(rule, spec *yaml.RNode, results *framework.Results) (*yaml.RNode, error) {
+	predicates := yaml.NewListRNode()
+
+	selector := yaml.NewMapRNode(nil)
+	if sel := spec.Field("clusterSelector"); sel != nil {
+		selector = sel.Value.Copy()
+	}
+
+	clusters, err := spec.Pipe(yaml.Lookup("clusters"))
+	if err != nil {
+		return nil, err
+	}
+
+	if clusters != nil && len(clusters.Content()) != 0 {
+		// The names are always strings, even if they look like numbers
+		names := yaml.NewListRNode()
+
+		for _, cluster := range clusters.Content() {
+			name, err := yaml.NewRNode(cluster).Pipe(yaml.Lookup("name"))
+			if err != nil {
+				return nil, err
+			}
+
+			err = names.PipeE(yaml.Append(yaml.NewStringRNode(yaml.GetValue(name)).YNode()))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		expr := yaml.NewMapRNode(nil)
+
+		err = expr.PipeE(
+			yaml.Tee(yaml.SetField("key", yaml.NewScalarRNode("name"))),
+			yaml.Tee(yaml.SetField("operator", yaml.NewScalarRNode("In"))),
+			yaml.Tee(yaml.SetField("values", names)),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = selector.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "matchExpressions"),
+			yaml.Append(expr.YNode()),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		Report(results, NewResult(framework.Warning,
+			"the listed clusters were converted to a selector on the 'name' label, which must be set on them",
+			rule, "spec.clusters"))
+	}
+
+	if len(selector.Content()) == 0 {
+		return predicates, nil // select all clusters
+	}
+
+	predicate := yaml.MustParse(basePlacementPredicate)
+
+	err = predicate.PipeE(
+		yaml.Lookup("requiredClusterSelector"),
+		yaml.SetField("labelSelector", selector),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return predicates, predicates.PipeE(yaml.Append(predicate.YNode()))
+}
+
+// tolerateUnavailable returns true if the Placement needs tolerations to select
+// unreachable and unavailable clusters. A Placement does not select them by
+// default, which is only equivalent to a PlacementRule which requires the
+// clusters to be available.
+func tolerateUnavailable(rule, spec *yaml.RNode, results *framework.Results) bool {
+	conditions, err := spec.Pipe(yaml.Lookup("clusterConditions"))
+	if err != nil || conditions == nil || len(conditions.Content()) == 0 {
+		return true
+	}
+
+	if len(conditions.Content()) == 1 {
+		condition := yaml.NewRNode(conditions.Content()[0])
+
+		condType, _ := condition.Pipe(yaml.Lookup("type"))
+		status, _ := condition.Pipe(yaml.Lookup("status"))
+
+		if yaml.GetValue(condType) == "ManagedClusterConditionAvailable" && yaml.GetValue(status) == "True" {
+			return false
+		}
+	}
+
+	Report(results, NewResult(framework.Warning,
+		"these clusterConditions can not be converted; the Placement selects clusters regardless of "+
+			"their conditions", rule, "spec.clusterConditions"))
+
+	return true
+}
@@ -0,0 +1,117 @@
+package wrappers
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// AutomationOnce runs the Ansible job the first time the policy is
+	// non-compliant, after which the mode is set to disabled by the controller.
+	AutomationOnce = "once"
+	// AutomationEveryEvent runs the Ansible job every time the policy becomes
+	// non-compliant on a cluster.
+	AutomationEveryEvent = "everyEvent"
+)
+
+// AutomationSpec configures a PolicyAutomation which runs an Ansible job when
+// a generated policy is non-compliant. It is only used when the job template
+// name is set.
+type AutomationSpec struct {
+	Mode            string                 `json:"mode,omitempty"`
+	JobTemplateName string                 `json:"jobTemplateName,omitempty"`
+	TowerSecret     string                 `json:"towerSecret,omitempty"`
+	ExtraVars       map[string]interface{} `json:"extraVars,omitempty"`
+	RescanAfter     string                 `json:"rescanAfter,omitempty"`
+}
+
+const basePolicyAutomation = `
+apiVersion: policy.open-cluster-management.io/v1beta1
+kind: PolicyAutomation
+spec:
+  eventHook: noncompliant
+  automationDef:
+    type: AnsibleJob
+`
+
+// NewPolicyAutomation returns a PolicyAutomation for the named policy, or nil
+// if no automation is configured. It is named after the policy, with a
+// "-policy-automation" suffix, which matches the OCM console.
+func (c PolicyWrapper) NewPolicyAutomation(policyName string) (*yaml.RNode, error) {
+	spec := c.Automation
+
+	if spec.JobTemplateName == "" {
+		if spec.Mode != "" || spec.TowerSecret != "" || len(spec.ExtraVars) != 0 || spec.RescanAfter != "" {
+			return nil, fmt.Errorf("automation.jobTemplateName is required when automation is configured")
+		}
+
+		return nil, nil
+	}
+
+	if spec.TowerSecret == "" {
+		return nil, fmt.Errorf("automation.towerSecret is required when automation is configured")
+	}
+
+	mode := spec.Mode
+	if mode == "" {
+		mode = AutomationOnce
+	}
+
+	if mode != AutomationOnce && mode != AutomationEveryEvent {
+		return nil, fmt.Errorf("unknown automation.mode '%v', must be '%v' or '%v'",
+			mode, AutomationOnce, AutomationEveryEvent)
+	}
+
+	automation := yaml.MustParse(basePolicyAutomation)
+
+	err := automation.SetName(policyName + "-policy-automation")
+	if err != nil {
+		return automation, err
+	}
+
+	err = automation.PipeE(
+		yaml.LookupCreate(yaml.MappingNode, "spec"),
+		yaml.Tee(yaml.SetField("policyRef", yaml.NewScalarRNode(policyName))),
+		yaml.Tee(yaml.SetField("mode", yaml.NewScalarRNode(mode))),
+	)
+	if err != nil {
+		return automation, err
+	}
+
+	if spec.RescanAfter != "" {
+		err = automation.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec"),
+			yaml.SetField("rescanAfter", yaml.NewScalarRNode(spec.RescanAfter)),
+		)
+		if err != nil {
+			return automation, err
+		}
+	}
+
+	err = automation.PipeE(
+		yaml.LookupCreate(yaml.MappingNode, "spec", "automationDef"),
+		yaml.Tee(yaml.SetField("name", yaml.NewScalarRNode(spec.JobTemplateName))),
+		yaml.Tee(yaml.SetField("secret", yaml.NewScalarRNode(spec.TowerSecret))),
+	)
+	if err != nil {
+		return automation, err
+	}
+
+	if len(spec.ExtraVars) != 0 {
+		extraVars, err := yaml.FromMap(spec.ExtraVars)
+		if err != nil {
+			return automation, fmt.Errorf("unable to use automation.extraVars: %w", err)
+		}
+
+		err = automation.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec", "automationDef"),
+			yaml.SetField("extra_vars", extraVars),
+		)
+		if err != nil {
+			return automation, err
+		}
+	}
+
+	return automation, nil
+}
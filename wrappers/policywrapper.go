@@ -0,0 +1,1371 @@
+package wrappers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+type PolicyWrapper struct {
+	AddContentHash        bool                        `json:"addContentHash,omitempty"`
+	AdditionalBindings    []AdditionalBinding         `json:"additionalBindings,omitempty"`
+	Automation            AutomationSpec              `json:"automation,omitempty"`
+	Categories            []string                    `json:"categories,omitempty"`
+	ConfigurationPolicy   *ConfigurationPolicyWrapper `json:"configurationPolicy,omitempty"` // used with wrapNonPolicies
+	Controls              []string                    `json:"controls,omitempty"`
+	ConsolidateManifests  bool                        `json:"consolidateManifests,omitempty"`
+	ConsolidatePlacements bool                        `json:"consolidatePlacements,omitempty"`
+	ConvertPlacementRules bool                        `json:"convertPlacementRules,omitempty"`
+	Disabled              bool                        `json:"disabled,omitempty"`
+	WrapNonPolicies       bool                        `json:"wrapNonPolicies,omitempty"`
+	DropNonPolicies       bool                        `json:"dropNonPolicies,omitempty"`
+	ExistingPolicies      string                      `json:"existingPolicies,omitempty"`
+	PlacementSpec         struct {
+		ClusterSelection      // the kind of placement to create, and its selector
+		IgnoreExisting   bool `json:"ignoreExisting,omitempty"`
+		Existing         struct {
+			Names       []string          `json:"names,omitempty"`
+			MatchLabels map[string]string `json:"matchLabels,omitempty"`
+		} `json:"existing,omitempty"` // Selects which placements from the input to bind to
+	} `json:"placement,omitempty"`
+	PolicyName        string `json:"policyName,omitempty"`
+	RecordSources     bool   `json:"recordSources,omitempty"`
+	RemediationAction string `json:"remediationAction,omitempty"`
+	Rollout           struct {
+		Waves []RolloutWave `json:"waves,omitempty"`
+	} `json:"rollout,omitempty"`
+	Standards     []string `json:"standards,omitempty"`
+	TargetVersion string   `json:"targetVersion,omitempty"`
+
+	InputSelection // include, exclude, and unselected
+
+	Results *framework.Results `json:"-"`
+}
+
+// AdditionalBinding configures another Placement and PlacementBinding for the
+// generated policies, which can override how they are applied on the selected
+// clusters. For example, policies can be informed on all clusters by the
+// primary binding, and enforced on some of them by an additional binding.
+type AdditionalBinding struct {
+	ClusterSelection
+	Name              string `json:"name"` // appended to the names of the placement and binding
+	RemediationAction string `json:"remediationAction,omitempty"`
+	SubFilter         string `json:"subFilter,omitempty"`
+}
+
+// RolloutWave is one group of clusters in a staged rollout. Each wave which is
+// not disabled gets its own Placement and PlacementBinding, so a wave can be
+// promoted by changing only its `disabled` field.
+type RolloutWave struct {
+	ClusterSelection
+	Name              string `json:"name"` // appended to the names of the placement and binding
+	Disabled          bool   `json:"disabled,omitempty"`
+	RemediationAction string `json:"remediationAction,omitempty"` // set as a bindingOverride
+}
+
+// ClusterSelection is the kind of placement to generate, either a Placement (the
+// default) or a PlacementRule, and which clusters it selects.
+type ClusterSelection struct {
+	Kind     string        `json:"kind,omitempty"`
+	Selector LabelSelector `json:"selector,omitempty"`
+}
+
+// LabelSelector selects clusters by their labels, like a Kubernetes label
+// selector. An empty selector matches all clusters.
+type LabelSelector struct {
+	MatchLabels      map[string]string          `json:"matchLabels,omitempty"`
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// LabelSelectorRequirement is one of the expressions in a LabelSelector.
+type LabelSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+func init() {
+	MustRegister(KindRegistration{
+		Kind:        "PolicyWrapper",
+		APIVersions: []string{APIVersionV1Alpha1, APIVersionV1Beta1},
+		Convert:     convertPolicyWrapper,
+		New: func() Config {
+			w := NewPolicyWrapper()
+
+			return &w
+		},
+	})
+}
+
+// NewPolicyWrapper returns a new PolicyWrapper with some defaults set.
+func NewPolicyWrapper() PolicyWrapper {
+	// Note: leaving things unset in the config will not overwrite these defaults
+	// with the "empty" golang values (eg not setting ConsolidateManifests will
+	// not make it act like it's false). Stuff "just works" like we'd want.
+	w := PolicyWrapper{
+		ConsolidateManifests:  true,
+		ConsolidatePlacements: false,
+		Disabled:              false,
+		WrapNonPolicies:       false,
+		DropNonPolicies:       false,
+		ExistingPolicies:      ExistingPassThrough,
+	}
+	w.PlacementSpec.IgnoreExisting = false
+
+	// The settings are decoded into this, so unset ones keep their defaults
+	cpw := NewConfigurationPolicyWrapper()
+	w.ConfigurationPolicy = &cpw
+
+	return w
+}
+
+// Init sets the name of the policy, unless it is empty, and where results are
+// reported.
+func (c *PolicyWrapper) Init(name string, results *framework.Results) {
+	if name != "" {
+		c.PolicyName = name
+	}
+
+	c.Results = results
+}
+
+// Filter wraps the given inputs into one or more policies, based on the
+// configuration. The output order is stable: each Policy is followed by its
+// PolicyAutomation (if configured), Placement and PlacementBinding (or, when
+// consolidating placements, all of the Policies and their PolicyAutomations are
+// followed by the one Placement and PlacementBinding), and then any inputs which
+// are passed through, in their original order.
+func (c PolicyWrapper) Filter(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+	if c.PolicyName == "" {
+		return operand, errors.New("PolicyWrapper requires a name, from the config or policyName")
+	}
+
+	target, err := c.checkTarget()
+	if err != nil {
+		return operand, err
+	}
+
+	policies, other, inputBindings := Split(operand)
+
+	if c.ConvertPlacementRules {
+		err = ConvertPlacementRules(other, target.PlacementAPIVersion, c.Results)
+		if err != nil {
+			return operand, err
+		}
+	}
+
+	inputPlacements, err := c.SelectInputPlacements(other)
+	if err != nil {
+		return operand, err
+	}
+
+	if !target.PlacementRules {
+		for _, placement := range inputPlacements {
+			if placement.GetKind() == "PlacementRule" {
+				return operand, fmt.Errorf("%v can not be used because PlacementRules are not available in "+
+					"targetVersion %v; set convertPlacementRules to convert it to a Placement",
+					ResourceID(placement), c.TargetVersion)
+			}
+		}
+	}
+
+	if len(c.Rollout.Waves) != 0 && len(inputPlacements) != 0 {
+		return operand, fmt.Errorf("rollout waves can not be used with existing placements from the input; " +
+			"set placement.ignoreExisting to generate placements for the waves")
+	}
+
+	if c.PlacementSpec.IgnoreExisting {
+		inputBindings = nil // treat them like any other input
+	} else if c.WrapNonPolicies {
+		// Existing placements and bindings are used, so they are never wrapped.
+		operand = without(withoutBindings(operand), inputPlacements)
+	}
+
+	if !c.WrapNonPolicies { // only wrap policies, leave others unchanged
+		operand = policies
+
+		for _, obj := range other {
+			if (IsPlacement(obj) || IsPlacementBinding(obj)) && !c.PlacementSpec.IgnoreExisting {
+				continue // already used or reported
+			}
+
+			msg := "not wrapped because it is not a policy, and wrapNonPolicies is not set"
+			if c.DropNonPolicies {
+				msg = "dropped because it is not a policy, and dropNonPolicies is set"
+			}
+
+			Report(c.Results, NewResult(framework.Info, msg, obj, ""))
+		}
+	}
+
+	operand, unselected, err := c.Select(operand, c.Results)
+	if err != nil {
+		return operand, err
+	}
+
+	if c.Unselected == UnselectedDrop {
+		other = without(other, unselected)
+	}
+
+	operand, existing, err := SplitExisting(operand, IsPolicy, nil, c.ExistingPolicies, c.Results)
+	if err != nil {
+		return operand, err
+	}
+
+	if c.WrapNonPolicies {
+		operand, err = c.WrapNonPolicyInputs(operand)
+		if err != nil {
+			return operand, err
+		}
+	}
+
+	// When everything was already wrapped, running again changes nothing.
+	alreadyWrapped := len(operand) == 0 && len(existing) != 0
+	merged := make(map[*yaml.RNode]bool)
+
+	sources := CaptureProvenance(operand)
+
+	_, err = ClearInternalAnnotations(operand)
+	if err != nil {
+		return operand, err
+	}
+
+	out := make([]*yaml.RNode, 0)
+
+	if c.ConsolidateManifests && !alreadyWrapped {
+		policy, err := c.NewPolicy(c.PolicyName)
+		if err != nil {
+			return out, fmt.Errorf("creating Policy %v for %v: %w", c.PolicyName, sources.Describe(operand), err)
+		}
+
+		for _, rsrc := range operand {
+			wrapped, err := c.WrapResource(rsrc)
+			if err != nil {
+				return out, sources.Wrap(rsrc, err)
+			}
+
+			err = policy.PipeE(
+				yaml.LookupCreate(yaml.SequenceNode, "spec", "policy-templates"),
+				yaml.Append(wrapped.YNode()),
+			)
+			if err != nil {
+				return out, sources.Wrap(rsrc, err)
+			}
+		}
+
+		policy, err = MergeExisting(policy, existing, merged, c.ExistingPolicies, "spec", "policy-templates")
+		if err != nil {
+			return out, err
+		}
+
+		err = c.SetSources(policy, sources, operand)
+		if err != nil {
+			return out, err
+		}
+
+		err = c.SetContentHash(policy)
+		if err != nil {
+			return out, err
+		}
+
+		if len(operand) == 0 {
+			Report(c.Results, NewResult(framework.Warning,
+				"the Policy is empty because there were no inputs to wrap",
+				policy, "spec.policy-templates"))
+		}
+
+		out = append(out, policy)
+
+		automation, err := c.NewPolicyAutomation(c.PolicyName)
+		if err != nil {
+			return out, err
+		}
+
+		if automation != nil {
+			out = append(out, automation)
+		}
+
+		bindings, err := c.NewBindings(c.PolicyName, []string{c.PolicyName}, inputPlacements)
+		if err != nil {
+			return out, err
+		}
+
+		out = append(out, bindings...)
+	} else if !alreadyWrapped {
+		policiesToBind := make([]string, len(operand)) // only used if consolidating placements
+
+		for i, rsrc := range operand {
+			baseName := fmt.Sprintf("%v-%v", c.PolicyName, i)
+
+			policy, err := c.NewPolicy(baseName)
+			if err != nil {
+				return out, sources.Wrap(rsrc, fmt.Errorf("creating Policy %v: %w", baseName, err))
+			}
+
+			wrapped, err := c.WrapResource(rsrc)
+			if err != nil {
+				return out, sources.Wrap(rsrc, err)
+			}
+
+			err = policy.PipeE(
+				yaml.LookupCreate(yaml.SequenceNode, "spec", "policy-templates"),
+				yaml.Append(wrapped.YNode()),
+			)
+			if err != nil {
+				return out, sources.Wrap(rsrc, err)
+			}
+
+			policy, err = MergeExisting(policy, existing, merged, c.ExistingPolicies, "spec", "policy-templates")
+			if err != nil {
+				return out, err
+			}
+
+			err = c.SetSources(policy, sources, []*yaml.RNode{rsrc})
+			if err != nil {
+				return out, err
+			}
+
+			err = c.SetContentHash(policy)
+			if err != nil {
+				return out, err
+			}
+
+			out = append(out, policy)
+
+			automation, err := c.NewPolicyAutomation(baseName)
+			if err != nil {
+				return out, err
+			}
+
+			if automation != nil {
+				out = append(out, automation)
+			}
+
+			if !c.ConsolidatePlacements {
+				bindings, err := c.NewBindings(baseName, []string{baseName}, nil)
+				if err != nil {
+					return out, err
+				}
+
+				out = append(out, bindings...)
+			} else {
+				policiesToBind[i] = baseName // only used if consolidating placements
+			}
+		}
+
+		if c.ConsolidatePlacements {
+			bindings, err := c.NewBindings(c.PolicyName, policiesToBind, inputPlacements)
+			if err != nil {
+				return out, err
+			}
+
+			out = append(out, bindings...)
+		}
+	}
+
+	out, extended, err := c.ExtendInputBindings(out, inputBindings)
+	if err != nil {
+		return out, err
+	}
+
+	for _, policy := range existing {
+		if !merged[policy] {
+			out = append(out, policy)
+		}
+	}
+
+	if c.Unselected != UnselectedDrop {
+		// Unselected non-policies are handled with the others, below
+		out = append(out, without(unselected, other)...)
+	}
+
+	if !c.DropNonPolicies { // emit non-policies unchanged
+		out = append(out, other...)
+	} else {
+		// Special case where the input placements and bindings are being used,
+		// but all other non-policies should be dropped.
+		out = append(out, inputPlacements...)
+
+		for _, binding := range inputBindings {
+			if extended[binding] {
+				out = append(out, binding)
+			} else {
+				Report(c.Results, NewResult(framework.Info,
+					"dropped because it is not a policy, and dropNonPolicies is set", binding, ""))
+			}
+		}
+	}
+
+	c.CheckBindingPlacements(out, inputBindings)
+
+	return out, nil
+}
+
+// ExtendInputBindings replaces each generated PlacementBinding which refers to
+// the same placement as a PlacementBinding from the input, by adding its
+// subjects to the input binding instead. It returns the new output, and which
+// input bindings were extended.
+func (c PolicyWrapper) ExtendInputBindings(
+	out []*yaml.RNode, inputBindings []*yaml.RNode,
+) ([]*yaml.RNode, map[*yaml.RNode]bool, error) {
+	extended := make(map[*yaml.RNode]bool)
+
+	if len(inputBindings) == 0 {
+		return out, extended, nil
+	}
+
+	isInput := make(map[*yaml.RNode]bool, len(inputBindings))
+	for _, binding := range inputBindings {
+		isInput[binding] = true
+	}
+
+	kept := make([]*yaml.RNode, 0, len(out))
+
+	for _, obj := range out {
+		if !IsPlacementBinding(obj) || isInput[obj] {
+			kept = append(kept, obj)
+
+			continue
+		}
+
+		var existing *yaml.RNode
+
+		for _, binding := range inputBindings {
+			if placementRefKey(binding) == placementRefKey(obj) {
+				existing = binding
+
+				break
+			}
+		}
+
+		if existing == nil {
+			kept = append(kept, obj)
+
+			continue
+		}
+
+		added, err := addSubjects(existing, obj)
+		if err != nil {
+			return out, extended, err
+		}
+
+		extended[existing] = true
+
+		Report(c.Results, NewResult(framework.Info,
+			fmt.Sprintf("added %v policies to this existing PlacementBinding instead of creating %v",
+				added, obj.GetName()),
+			existing, "subjects"))
+	}
+
+	return kept, extended, nil
+}
+
+// CheckBindingPlacements warns about each input PlacementBinding in the output
+// which refers to a placement that is not in the output.
+func (c PolicyWrapper) CheckBindingPlacements(out []*yaml.RNode, inputBindings []*yaml.RNode) {
+	inOutput := make(map[*yaml.RNode]bool, len(out))
+	placements := make(map[string]bool)
+
+	for _, obj := range out {
+		inOutput[obj] = true
+
+		if IsPlacement(obj) {
+			placements[obj.GetKind()+"/"+obj.GetName()] = true
+		}
+	}
+
+	for _, binding := range inputBindings {
+		if !inOutput[binding] || placements[placementRefKey(binding)] {
+			continue
+		}
+
+		Report(c.Results, NewResult(framework.Warning,
+			"this PlacementBinding refers to a placement which is not in the output",
+			binding, "placementRef"))
+	}
+}
+
+// placementRefKey returns the kind and name of the placement referred to by the
+// PlacementBinding, in the form "kind/name".
+func placementRefKey(binding *yaml.RNode) string {
+	kind, _ := binding.Pipe(yaml.Lookup("placementRef", "kind"))
+	name, _ := binding.Pipe(yaml.Lookup("placementRef", "name"))
+
+	return yaml.GetValue(kind) + "/" + yaml.GetValue(name)
+}
+
+// addSubjects appends the subjects of the source binding to the target binding,
+// skipping any which are already there. It returns how many were added.
+func addSubjects(target, source *yaml.RNode) (int, error) {
+	existing := make(map[string]bool)
+
+	targetSubjects, err := target.Pipe(yaml.Lookup("subjects"))
+	if err != nil {
+		return 0, err
+	}
+
+	if targetSubjects != nil {
+		for _, subject := range targetSubjects.Content() {
+			existing[subjectKey(yaml.NewRNode(subject))] = true
+		}
+	}
+
+	sourceSubjects, err := source.Pipe(yaml.Lookup("subjects"))
+	if err != nil || sourceSubjects == nil {
+		return 0, err
+	}
+
+	added := 0
+
+	for _, subject := range sourceSubjects.Content() {
+		if existing[subjectKey(yaml.NewRNode(subject))] {
+			continue
+		}
+
+		err = target.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "subjects"),
+			yaml.Append(subject),
+		)
+		if err != nil {
+			return added, err
+		}
+
+		added++
+	}
+
+	return added, nil
+}
+
+// subjectKey returns the kind and name of a PlacementBinding subject.
+func subjectKey(subject *yaml.RNode) string {
+	kind, _ := subject.Pipe(yaml.Lookup("kind"))
+	name, _ := subject.Pipe(yaml.Lookup("name"))
+
+	return yaml.GetValue(kind) + "/" + yaml.GetValue(name)
+}
+
+// WrapNonPolicyInputs wraps the inputs which are not policies in
+// ConfigurationPolicies, because only policy kinds are valid in a Policy's
+// templates. The ConfigurationPolicyWrapper is configured by the
+// `configurationPolicy` settings, and its policy is named like this wrapper's
+// by default. The policies from the input are returned first, followed by the
+// new ConfigurationPolicies. Inputs of the kinds generated by the wrappers,
+// like Placements, can not be wrapped, so they are left out; they are passed
+// through unchanged with the other non-policies.
+func (c PolicyWrapper) WrapNonPolicyInputs(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+	policies := make([]*yaml.RNode, 0, len(operand))
+	nonPolicies := make([]*yaml.RNode, 0)
+
+	for _, rsrc := range operand {
+		switch {
+		case IsPolicyKind(rsrc):
+			policies = append(policies, rsrc)
+		case IsGenerated(rsrc):
+			Report(c.Results, NewResult(framework.Info,
+				fmt.Sprintf("not wrapped because a %v can not be in a policy template", rsrc.GetKind()),
+				rsrc, ""))
+		default:
+			nonPolicies = append(nonPolicies, rsrc)
+		}
+	}
+
+	if len(nonPolicies) == 0 {
+		return policies, nil
+	}
+
+	w := NewConfigurationPolicyWrapper()
+	if c.ConfigurationPolicy != nil {
+		w = *c.ConfigurationPolicy
+	}
+
+	if w.PolicyName == "" {
+		w.PolicyName = c.PolicyName
+	}
+
+	if w.TargetVersion == "" {
+		w.TargetVersion = c.TargetVersion
+	}
+
+	w.Results = c.Results
+
+	wrapped, err := w.Filter(nonPolicies)
+	if err != nil {
+		return operand, fmt.Errorf("wrapping non-policies in a ConfigurationPolicy: %w", err)
+	}
+
+	// Only the new ConfigurationPolicies are templates; any inputs which the
+	// settings did not select are passed through with the other non-policies.
+	return append(policies, CreatedObjects(wrapped, nonPolicies)...), nil
+}
+
+// SelectInputPlacements returns the Placements and PlacementRules from the input
+// which the policies should be bound to. If any names or labels are configured
+// in `placement.existing`, all matching placements are selected, and it is an
+// error if none match. Otherwise, the first placement from the input is used.
+// When neither the manifests nor the placements are consolidated, each policy
+// gets its own placement, so none are selected, and it is an error to
+// configure `placement.existing`.
+func (c PolicyWrapper) SelectInputPlacements(other []*yaml.RNode) ([]*yaml.RNode, error) {
+	if c.PlacementSpec.IgnoreExisting {
+		return nil, nil
+	}
+
+	existing := c.PlacementSpec.Existing
+	useSelector := len(existing.Names) != 0 || len(existing.MatchLabels) != 0
+
+	if !c.ConsolidateManifests && !c.ConsolidatePlacements {
+		if useSelector {
+			return nil, errors.New("placement.existing can not be used when consolidateManifests and " +
+				"consolidatePlacements are both false, because each Policy gets its own Placement; " +
+				"set consolidatePlacements to bind all of the Policies to the existing placements")
+		}
+
+		for _, obj := range other {
+			if IsPlacement(obj) {
+				Report(c.Results, NewResult(framework.Info,
+					"not bound because each Policy gets its own Placement when consolidateManifests and "+
+						"consolidatePlacements are both false", obj, ""))
+			}
+		}
+
+		return nil, nil
+	}
+
+	selected := make([]*yaml.RNode, 0)
+
+	for _, obj := range other {
+		if !IsPlacement(obj) {
+			continue
+		}
+
+		if !useSelector {
+			if len(selected) == 0 {
+				selected = append(selected, obj)
+			} else {
+				Report(c.Results, NewResult(framework.Warning,
+					"only the first Placement or PlacementRule in the input is used, this one was not bound; "+
+						"set placement.existing to select multiple placements",
+					obj, ""))
+			}
+
+			continue
+		}
+
+		if matchesExisting(obj, existing.Names, existing.MatchLabels) {
+			selected = append(selected, obj)
+		} else {
+			Report(c.Results, NewResult(framework.Info,
+				"this placement was not selected by placement.existing, so it was not bound",
+				obj, ""))
+		}
+	}
+
+	if useSelector && len(selected) == 0 {
+		return nil, fmt.Errorf("no Placement or PlacementRule in the input matched placement.existing "+
+			"(names: %v, matchLabels: %v)", existing.Names, existing.MatchLabels)
+	}
+
+	return selected, nil
+}
+
+// matchesExisting returns true if the object has one of the given names (when
+// any are given) and all of the given labels.
+func matchesExisting(obj *yaml.RNode, names []string, matchLabels map[string]string) bool {
+	if len(names) != 0 {
+		found := false
+
+		for _, name := range names {
+			if obj.GetName() == name {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	labels := obj.GetLabels()
+
+	for key, val := range matchLabels {
+		if actual, ok := labels[key]; !ok || actual != val {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewPlacementBindings returns a PlacementBinding connecting the given policies
+// to each of the given placements. If there are no placements, it returns one
+// binding to the placement that would be created by NewPlacement. When there
+// are multiple placements, the bindings' names include the placement names.
+func (c PolicyWrapper) NewPlacementBindings(
+	baseName string, policies []string, placements []*yaml.RNode,
+) ([]*yaml.RNode, error) {
+	if len(placements) == 0 {
+		binding, err := c.NewPlacementBinding(baseName, policies, nil)
+
+		return []*yaml.RNode{binding}, err
+	}
+
+	bindings := make([]*yaml.RNode, 0, len(placements))
+
+	for _, placement := range placements {
+		name := baseName
+		if len(placements) > 1 {
+			name = baseName + "-" + placement.GetName()
+		}
+
+		binding, err := c.NewPlacementBinding(name, policies, placement)
+		if err != nil {
+			return bindings, err
+		}
+
+		bindings = append(bindings, binding)
+	}
+
+	return bindings, nil
+}
+
+// NewBindings returns the placements and bindings for the given policies: either
+// bindings to the given input placements, the rollout waves, or a new placement
+// and binding based on the configuration. They are followed by any additional
+// bindings.
+func (c PolicyWrapper) NewBindings(baseName string, policies []string, inputPlacements []*yaml.RNode) (
+	[]*yaml.RNode, error,
+) {
+	out := make([]*yaml.RNode, 0)
+
+	if len(c.Rollout.Waves) != 0 {
+		waves, err := c.NewRolloutBindings(baseName, policies)
+		if err != nil {
+			return out, err
+		}
+
+		out = append(out, waves...)
+	} else {
+		if len(inputPlacements) == 0 {
+			placement, err := c.NewPlacement(baseName)
+			if err != nil {
+				return out, err
+			}
+
+			out = append(out, placement)
+		}
+
+		bindings, err := c.NewPlacementBindings(baseName, policies, inputPlacements)
+		if err != nil {
+			return out, err
+		}
+
+		out = append(out, bindings...)
+	}
+
+	additional, err := c.NewAdditionalBindings(baseName, policies)
+	if err != nil {
+		return out, err
+	}
+
+	return append(out, additional...), nil
+}
+
+// NewRolloutBindings returns a Placement and PlacementBinding for each of the
+// rollout waves which is not disabled, in the configured order.
+func (c PolicyWrapper) NewRolloutBindings(baseName string, policies []string) ([]*yaml.RNode, error) {
+	out := make([]*yaml.RNode, 0, 2*len(c.Rollout.Waves))
+	seen := make(map[string]bool, len(c.Rollout.Waves))
+
+	for i, wave := range c.Rollout.Waves {
+		if wave.Name == "" {
+			return out, fmt.Errorf("rollout.waves[%v] must have a name", i)
+		}
+
+		if seen[wave.Name] {
+			return out, fmt.Errorf("rollout.waves[%v] has the same name as an earlier wave: '%v'", i, wave.Name)
+		}
+
+		seen[wave.Name] = true
+
+		if wave.Disabled {
+			continue
+		}
+
+		bindings, err := c.newOverrideBindings(baseName, policies, AdditionalBinding{
+			ClusterSelection:  wave.ClusterSelection,
+			Name:              wave.Name,
+			RemediationAction: wave.RemediationAction,
+		})
+		if err != nil {
+			return out, fmt.Errorf("rollout.waves[%v]: %w", i, err)
+		}
+
+		out = append(out, bindings...)
+	}
+
+	if len(out) == 0 {
+		Report(c.Results, NewResult(framework.Warning,
+			fmt.Sprintf("all rollout waves are disabled, so the policies for %v are not bound to any clusters",
+				baseName),
+			nil, "rollout.waves"))
+	}
+
+	return out, nil
+}
+
+// NewAdditionalBindings returns a Placement and PlacementBinding for each of the
+// configured additionalBindings, connecting the given policies to them. The
+// bindings have the configured bindingOverrides and subFilter set.
+func (c PolicyWrapper) NewAdditionalBindings(baseName string, policies []string) ([]*yaml.RNode, error) {
+	out := make([]*yaml.RNode, 0, 2*len(c.AdditionalBindings))
+
+	for i, additional := range c.AdditionalBindings {
+		bindings, err := c.newOverrideBindings(baseName, policies, additional)
+		if err != nil {
+			return out, fmt.Errorf("additionalBindings[%v]: %w", i, err)
+		}
+
+		out = append(out, bindings...)
+	}
+
+	return out, nil
+}
+
+// newOverrideBindings returns a Placement using the selectors from the given
+// config, and a PlacementBinding to it with its overrides and subFilter set.
+func (c PolicyWrapper) newOverrideBindings(
+	baseName string, policies []string, additional AdditionalBinding,
+) ([]*yaml.RNode, error) {
+	if additional.Name == "" {
+		return nil, fmt.Errorf("a name is required")
+	}
+
+	if additional.RemediationAction != "" && !strings.EqualFold(additional.RemediationAction, "enforce") {
+		return nil, fmt.Errorf("remediationAction is '%v', but only 'Enforce' can be used to override "+
+			"a binding", additional.RemediationAction)
+	}
+
+	if additional.SubFilter != "" && additional.SubFilter != "restricted" {
+		return nil, fmt.Errorf("subFilter is '%v', must be 'restricted'", additional.SubFilter)
+	}
+
+	// Build the placement and binding with this selection
+	sub := c
+	sub.PlacementSpec.ClusterSelection = additional.ClusterSelection
+
+	name := baseName + "-" + additional.Name
+
+	placement, err := sub.NewPlacement(name)
+	if err != nil {
+		return nil, err
+	}
+
+	binding, err := sub.NewPlacementBinding(name, policies, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if additional.RemediationAction != "" {
+		err = binding.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "bindingOverrides"),
+			yaml.SetField("remediationAction", yaml.NewScalarRNode(additional.RemediationAction)),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if additional.SubFilter != "" {
+		err = binding.PipeE(yaml.SetField("subFilter", yaml.NewScalarRNode(additional.SubFilter)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return []*yaml.RNode{placement, binding}, nil
+}
+
+// WrapResource returns a yaml map with one field: `objectDefinition`, which
+// contains the input yaml node. Comments on the input are preserved.
+func (c PolicyWrapper) WrapResource(res *yaml.RNode) (*yaml.RNode, error) {
+	// The input might also be passed through, so it must not be shared with
+	// the output.
+	res = res.Copy()
+
+	LiftDocumentComments(res)
+
+	wrapped := yaml.NewMapRNode(nil)
+
+	err := wrapped.PipeE(
+		yaml.SetField("objectDefinition", res),
+	)
+
+	return wrapped, err
+}
+
+const basePolicy = `
+apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+`
+
+// NewPolicy returns a Policy based on the configuration, ready to have objects
+// inserted into `spec.policy-templates`.
+func (c PolicyWrapper) NewPolicy(name string) (*yaml.RNode, error) {
+	policy := yaml.MustParse(basePolicy)
+
+	err := policy.SetName(name)
+	if err != nil {
+		return policy, err
+	}
+
+	annos := make(map[string]string)
+	annos["policy.open-cluster-management.io/categories"] = strings.Join(c.Categories, ",")
+	annos["policy.open-cluster-management.io/controls"] = strings.Join(c.Controls, ",")
+	annos["policy.open-cluster-management.io/standards"] = strings.Join(c.Standards, ",")
+	policy.SetAnnotations(annos)
+
+	if c.Disabled {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec"),
+			yaml.SetField("disabled", yaml.NewScalarRNode("true")),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	if c.RemediationAction != "" {
+		err := policy.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, "spec"),
+			yaml.SetField("remediationAction", yaml.NewScalarRNode(c.RemediationAction)),
+		)
+		if err != nil {
+			return policy, err
+		}
+	}
+
+	return policy, nil
+}
+
+// SetSources sets the SourceAnnotation on the policy to describe the given
+// wrapped inputs, if enabled in the configuration.
+func (c PolicyWrapper) SetSources(policy *yaml.RNode, sources Sources, wrapped []*yaml.RNode) error {
+	if !c.RecordSources || len(wrapped) == 0 {
+		return nil
+	}
+
+	return policy.PipeE(yaml.SetAnnotation(SourceAnnotation, sources.Describe(wrapped)))
+}
+
+// ContentHashAnnotation is set on generated Policies when AddContentHash is
+// enabled. Its value is a hash of the Policy's spec, so that tooling can easily
+// detect real changes to the Policy.
+const ContentHashAnnotation = "policy-transformer/content-hash"
+
+// SetContentHash sets the ContentHashAnnotation on the policy, if enabled in
+// the configuration. It should be called after the policy's spec is complete.
+func (c PolicyWrapper) SetContentHash(policy *yaml.RNode) error {
+	if !c.AddContentHash {
+		return nil
+	}
+
+	spec := policy.Field("spec")
+	if spec == nil {
+		return policy.PipeE(yaml.SetAnnotation(ContentHashAnnotation, ContentHash(nil)))
+	}
+
+	// The JSON encoding has sorted keys, so this is independent of field order.
+	specJSON, err := spec.Value.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	return policy.PipeE(yaml.SetAnnotation(ContentHashAnnotation, ContentHash(specJSON)))
+}
+
+// ContentHash returns the hex-encoded sha256 hash of the content.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
+
+const basePlacement = `
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+spec:
+  predicates: []
+`
+
+const basePlacementPredicate = `
+requiredClusterSelector: {}
+`
+
+const basePlacementRule = `
+apiVersion: apps.open-cluster-management.io/v1
+kind: PlacementRule
+spec: {}
+`
+
+// NewPlacement returns a Placement or PlacementRule based on the configuration.
+func (c PolicyWrapper) NewPlacement(baseName string) (*yaml.RNode, error) {
+	var placement *yaml.RNode
+
+	target, err := LookupTarget(c.TargetVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := NewLabelSelector(c.PlacementSpec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := c.placementKind(target)
+	converted := kind != c.PlacementSpec.Kind && c.PlacementSpec.Kind == "PlacementRule"
+
+	if converted && !c.ConvertPlacementRules {
+		Report(c.Results, NewResult(framework.Warning,
+			fmt.Sprintf("a Placement was generated for 'placement-%v' instead of a PlacementRule, because "+
+				"PlacementRules are not available in targetVersion %v", baseName, c.TargetVersion),
+			nil, "placement.kind"))
+	}
+
+	switch kind {
+	case "PlacementRule":
+		placement = yaml.MustParse(basePlacementRule)
+
+		err = placement.PipeE(
+			yaml.Lookup("spec"),
+			yaml.SetField("clusterSelector", selector),
+		)
+		if err != nil {
+			return nil, err
+		}
+	case "", "Placement":
+		predicate := yaml.MustParse(basePlacementPredicate)
+
+		err = predicate.PipeE(
+			yaml.Lookup("requiredClusterSelector"),
+			yaml.SetField("labelSelector", selector),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		placement = yaml.MustParse(basePlacement)
+		placement.SetApiVersion(target.PlacementAPIVersion)
+
+		err = placement.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "spec", "predicates"),
+			yaml.Append(predicate.YNode()),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if converted {
+			// A PlacementRule also selects clusters which are not available.
+			err = placement.PipeE(
+				yaml.Lookup("spec"),
+				yaml.SetField("tolerations", yaml.MustParse(basePlacementTolerations)),
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown placement kind '%v', must be 'Placement' or 'PlacementRule'",
+			c.PlacementSpec.Kind)
+	}
+
+	placement.SetName("placement-" + baseName)
+
+	return placement, nil
+}
+
+// placementKind returns the kind of placement to generate, which is always a
+// Placement when converting PlacementRules, or when they are not available in
+// the target.
+func (c PolicyWrapper) placementKind(target TargetProfile) string {
+	if c.PlacementSpec.Kind == "PlacementRule" && (c.ConvertPlacementRules || !target.PlacementRules) {
+		return "Placement"
+	}
+
+	return c.PlacementSpec.Kind
+}
+
+// checkTarget returns the profile for the targetVersion, and checks that the
+// configured bindings only use features which it supports.
+func (c PolicyWrapper) checkTarget() (TargetProfile, error) {
+	target, err := LookupTarget(c.TargetVersion)
+	if err != nil {
+		return target, err
+	}
+
+	for i, additional := range c.AdditionalBindings {
+		err = checkFeature(target, c.TargetVersion, FeatureBindingOverrides,
+			additional.RemediationAction != "" || additional.SubFilter != "", fmt.Sprintf("additionalBindings[%v]", i))
+		if err != nil {
+			return target, err
+		}
+	}
+
+	for i, wave := range c.Rollout.Waves {
+		err = checkFeature(target, c.TargetVersion, FeatureBindingOverrides,
+			wave.RemediationAction != "", fmt.Sprintf("rollout.waves[%v].remediationAction", i))
+		if err != nil {
+			return target, err
+		}
+	}
+
+	return target, nil
+}
+
+// NewLabelSelector returns the selector as a yaml map. The matchExpressions are
+// always included, even when empty, and each expression has its values listed
+// (also when empty), for consistency with earlier versions of the output.
+func NewLabelSelector(sel LabelSelector) (*yaml.RNode, error) {
+	node := yaml.NewMapRNode(nil)
+
+	if len(sel.MatchLabels) != 0 {
+		err := node.PipeE(yaml.SetField("matchLabels", NewSortedMapRNode(sel.MatchLabels)))
+		if err != nil {
+			return node, err
+		}
+	}
+
+	exprs, err := node.Pipe(yaml.LookupCreate(yaml.SequenceNode, "matchExpressions"))
+	if err != nil {
+		return node, err
+	}
+
+	for _, req := range sel.MatchExpressions {
+		expr := yaml.NewMapRNode(nil)
+
+		err = expr.PipeE(
+			yaml.Tee(yaml.SetField("key", yaml.NewStringRNode(req.Key))),
+			yaml.Tee(yaml.SetField("operator", yaml.NewStringRNode(req.Operator))),
+		)
+		if err != nil {
+			return node, err
+		}
+
+		values, err := expr.Pipe(yaml.LookupCreate(yaml.SequenceNode, "values"))
+		if err != nil {
+			return node, err
+		}
+
+		for _, val := range req.Values {
+			err = values.PipeE(yaml.Append(yaml.NewStringRNode(val).YNode()))
+			if err != nil {
+				return node, err
+			}
+		}
+
+		err = exprs.PipeE(yaml.Append(expr.YNode()))
+		if err != nil {
+			return node, err
+		}
+	}
+
+	return node, nil
+}
+
+const basePlacementBinding = `
+apiVersion: policy.open-cluster-management.io/v1
+kind: PlacementBinding
+`
+
+// NewPlacementBinding returns a PlacementBinding connecting the given policies
+// to the given placement, referenced by its actual name. If the input placement
+// is nil, it will connect the policies to the placement that would be created
+// by NewPlacement with this configuration.
+func (c PolicyWrapper) NewPlacementBinding(
+	baseName string, policies []string, placement *yaml.RNode,
+) (*yaml.RNode, error) {
+	binding := yaml.MustParse(basePlacementBinding)
+
+	var placementName, placementKind, placementGroup *yaml.RNode
+
+	if placement == nil {
+		placementName = yaml.NewScalarRNode("placement-" + baseName)
+
+		target, err := LookupTarget(c.TargetVersion)
+		if err != nil {
+			return binding, err
+		}
+
+		if c.placementKind(target) == "PlacementRule" {
+			placementKind = yaml.NewScalarRNode("PlacementRule")
+			placementGroup = yaml.NewScalarRNode("apps.open-cluster-management.io")
+		} else {
+			placementKind = yaml.NewScalarRNode("Placement")
+			placementGroup = yaml.NewScalarRNode("cluster.open-cluster-management.io")
+		}
+	} else {
+		placementName = yaml.NewScalarRNode(placement.GetName())
+		placementKind = yaml.NewScalarRNode(placement.GetKind())
+		placementGroup = yaml.NewScalarRNode(strings.Split(placement.GetApiVersion(), "/")[0])
+	}
+
+	err := binding.PipeE(
+		yaml.LookupCreate(yaml.MappingNode, "placementRef"),
+		yaml.Tee(yaml.SetField("name", placementName)),
+		yaml.Tee(yaml.SetField("kind", placementKind)),
+		yaml.Tee(yaml.SetField("apiGroup", placementGroup)),
+	)
+	if err != nil {
+		return binding, err
+	}
+
+	for _, policy := range policies {
+		subject := yaml.NewMapRNode(nil)
+
+		err = subject.PipeE(
+			yaml.Tee(yaml.SetField("name", yaml.NewScalarRNode(policy))),
+			yaml.Tee(yaml.SetField("kind", yaml.NewScalarRNode("Policy"))),
+			yaml.Tee(yaml.SetField("apiGroup", yaml.NewScalarRNode("policy.open-cluster-management.io"))),
+		)
+		if err != nil {
+			return binding, err
+		}
+
+		err = binding.PipeE(
+			yaml.LookupCreate(yaml.SequenceNode, "subjects"),
+			yaml.Append(subject.YNode()),
+		)
+		if err != nil {
+			return binding, err
+		}
+	}
+
+	binding.SetName("binding-" + baseName)
+	return binding, nil
+}
+
+// BuildMatchExpressions returns a list of yaml map nodes, formatted to be used
+// as items in a `MatchExpressions` list, sorted by key. Note: due to implementation quirks,
+// the items in the list have to be individually unwrapped as YNodes in order to
+// be appended to an existing yaml object.
+func BuildMatchExpressions(sel map[string]string) ([]*yaml.RNode, error) {
+	list := make([]*yaml.RNode, 0)
+
+	// Iterate in a sorted order so that the output is the same on every run.
+	for _, key := range yaml.SortedMapKeys(sel) {
+		val := sel[key]
+
+		item := yaml.NewMapRNode(nil)
+
+		var err error
+		if val != "" {
+			err = item.PipeE(
+				yaml.Tee(yaml.SetField("key", yaml.NewScalarRNode(key))),
+				yaml.Tee(yaml.SetField("operator", yaml.NewScalarRNode(`In`))),
+				yaml.LookupCreate(yaml.SequenceNode, "values"),
+				yaml.Append(yaml.NewStringRNode(val).YNode()),
+			)
+		} else {
+			err = item.PipeE(
+				yaml.Tee(yaml.SetField("key", yaml.NewScalarRNode(key))),
+				yaml.Tee(yaml.SetField("operator", yaml.NewScalarRNode(`Exists`))),
+				yaml.LookupCreate(yaml.SequenceNode, "values"),
+			)
+		}
+		if err != nil {
+			return list, err
+		}
+
+		list = append(list, item)
+	}
+
+	return list, nil
+}
+
+// Split separates the inputs into policies, and non-policies. It also finds and
+// returns the PlacementBindings in the input, which are included in the
+// non-policies.
+func Split(operand []*yaml.RNode) (policies, other, bindings []*yaml.RNode) {
+	policies = make([]*yaml.RNode, 0)
+	other = make([]*yaml.RNode, 0)
+	bindings = make([]*yaml.RNode, 0)
+
+	// Separate policy objects from non-policies
+	for _, obj := range operand {
+		if !IsPolicyKind(obj) {
+			other = append(other, obj)
+			continue
+		}
+
+		policies = append(policies, obj)
+	}
+
+	for _, obj := range other {
+		if IsPlacementBinding(obj) {
+			bindings = append(bindings, obj)
+		}
+	}
+
+	return policies, other, bindings
+}
+
+// IsPolicyKind returns true if the object is one of the policy kinds, like a
+// ConfigurationPolicy, which can be a template in a Policy.
+func IsPolicyKind(obj *yaml.RNode) bool {
+	return obj.GetApiVersion() == "policy.open-cluster-management.io/v1" &&
+		strings.HasSuffix(obj.GetKind(), "Policy")
+}
+
+// IsPlacement returns true if the object is a Placement or PlacementRule.
+func IsPlacement(obj *yaml.RNode) bool {
+	apiV := obj.GetApiVersion()
+
+	if strings.HasPrefix(apiV, "cluster.open-cluster-management.io/") && obj.GetKind() == "Placement" {
+		return true
+	}
+
+	return apiV == "apps.open-cluster-management.io/v1" && obj.GetKind() == "PlacementRule"
+}
+
+// IsPlacementBinding returns true if the object is a PlacementBinding.
+func IsPlacementBinding(obj *yaml.RNode) bool {
+	return obj.GetApiVersion() == "policy.open-cluster-management.io/v1" && obj.GetKind() == "PlacementBinding"
+}
+
+// without returns the objects which are not in the removed list.
+func without(objs []*yaml.RNode, removed []*yaml.RNode) []*yaml.RNode {
+	isRemoved := make(map[*yaml.RNode]bool, len(removed))
+	for _, obj := range removed {
+		isRemoved[obj] = true
+	}
+
+	filtered := make([]*yaml.RNode, 0, len(objs))
+
+	for _, obj := range objs {
+		if !isRemoved[obj] {
+			filtered = append(filtered, obj)
+		}
+	}
+
+	return filtered
+}
+
+// withoutBindings returns the objects which are not PlacementBindings.
+func withoutBindings(objs []*yaml.RNode) []*yaml.RNode {
+	filtered := make([]*yaml.RNode, 0, len(objs))
+
+	for _, obj := range objs {
+		if !IsPlacementBinding(obj) {
+			filtered = append(filtered, obj)
+		}
+	}
+
+	return filtered
+}
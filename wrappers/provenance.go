@@ -0,0 +1,124 @@
+package wrappers
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// OriginAnnotation is set by kustomize on resources when the `originAnnotations`
+// build metadata option is enabled in the kustomization.
+const OriginAnnotation = "config.kubernetes.io/origin"
+
+// SourceAnnotation is set on generated ConfigurationPolicies and Policies when
+// the recordSources option is enabled, to record which input(s) they came from.
+// It is never set on the wrapped objects themselves.
+const SourceAnnotation = "policy-transformer/source"
+
+// Provenance describes where an input resource came from. It must be captured
+// before the internal annotations are cleared from the resource.
+type Provenance struct {
+	ID    string // see ResourceID
+	Path  string
+	Index string
+	Repo  string
+}
+
+// Sources maps inputs to their Provenance.
+type Sources map[*yaml.RNode]Provenance
+
+// GetProvenance returns the Provenance of the resource, based on its path and
+// index annotations, or the kustomize origin annotation if those are not set.
+func GetProvenance(rsrc *yaml.RNode) Provenance {
+	prov := Provenance{ID: ResourceID(rsrc)}
+	prov.Path, prov.Index, _ = kioutil.GetFileAnnotations(rsrc)
+
+	if prov.Path == "" {
+		if originStr := rsrc.GetAnnotations()[OriginAnnotation]; originStr != "" {
+			var origin struct {
+				Path string `yaml:"path,omitempty"`
+				Repo string `yaml:"repo,omitempty"`
+			}
+
+			if err := yaml.Unmarshal([]byte(originStr), &origin); err == nil {
+				prov.Path = origin.Path
+				prov.Repo = origin.Repo
+			}
+		}
+	}
+
+	return prov
+}
+
+// CaptureProvenance returns the Provenance of each of the inputs.
+func CaptureProvenance(operand []*yaml.RNode) Sources {
+	sources := make(Sources, len(operand))
+
+	for _, rsrc := range operand {
+		sources[rsrc] = GetProvenance(rsrc)
+	}
+
+	return sources
+}
+
+// Source returns a description of the file the resource came from, or an empty
+// string if that is not known.
+func (p Provenance) Source() string {
+	if p.Path == "" {
+		return ""
+	}
+
+	source := p.Path
+	if p.Repo != "" {
+		source = p.Repo + "/" + source
+	}
+
+	if p.Index != "" {
+		source += fmt.Sprintf(" (document %v)", p.Index)
+	}
+
+	return source
+}
+
+// String returns the resource's ID, and its source if that is known.
+func (p Provenance) String() string {
+	if source := p.Source(); source != "" {
+		return p.ID + " from " + source
+	}
+
+	return p.ID
+}
+
+// Wrap adds the provenance of the resource to the error, if it is not nil.
+func (s Sources) Wrap(rsrc *yaml.RNode, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	prov, found := s[rsrc]
+	if !found {
+		prov = GetProvenance(rsrc)
+	}
+
+	return fmt.Errorf("%v: %w", prov, err)
+}
+
+// Describe returns a comma-separated list of the sources of the resources. For
+// resources with an unknown source, their ID is used instead.
+func (s Sources) Describe(group []*yaml.RNode) string {
+	descriptions := make([]string, 0, len(group))
+
+	for _, rsrc := range group {
+		prov := s[rsrc]
+
+		if source := prov.Source(); source != "" {
+			descriptions = append(descriptions, source)
+		} else {
+			descriptions = append(descriptions, prov.ID)
+		}
+	}
+
+	return strings.Join(descriptions, ", ")
+}
@@ -0,0 +1,327 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// APIVersionV1Alpha1 is the original apiVersion of the configs for the
+	// kinds built into the PolicyTransformer. It is deprecated.
+	APIVersionV1Alpha1 = "policy.open-cluster-management.io/v1alpha1"
+	// APIVersionV1Beta1 is the latest apiVersion of the configs for the kinds
+	// built into the PolicyTransformer.
+	APIVersionV1Beta1 = "policy.open-cluster-management.io/v1beta1"
+)
+
+// Config is implemented by pointers to the config types of each kind.
+type Config interface {
+	kio.Filter
+
+	// Init is called after the spec is decoded into the config, with the name
+	// from the config's metadata, and where results should be reported. An
+	// empty name must not replace a name which is set in the spec.
+	Init(name string, results *framework.Results)
+}
+
+// KindRegistration describes a kind of config handled by the PolicyTransformer.
+type KindRegistration struct {
+	Kind string
+
+	// APIVersions are the supported versions, oldest first. The last one is
+	// the latest, and the others are deprecated.
+	APIVersions []string
+
+	// Convert changes the spec of a config from one of the older apiVersions to
+	// the latest one, in place. It may be nil if the spec is the same in all of
+	// the versions.
+	Convert func(fromVersion string, spec *yaml.RNode) error
+
+	// New returns a config of this kind with its defaults set. The config's
+	// spec is decoded into it with encoding/json.
+	New func() Config
+
+	// Schema is used to validate the config's spec before it is decoded. If it
+	// is nil, a schema is generated from the type returned by New, with the
+	// CommonSpec fields added.
+	Schema *spec.Schema
+}
+
+// Registry holds the kinds of config which can be handled.
+type Registry struct {
+	kinds map[string]KindRegistration
+	order []string
+}
+
+// DefaultRegistry is used by the PolicyTransformer. The built-in kinds register
+// themselves in it from the files which define them.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{kinds: make(map[string]KindRegistration)}
+}
+
+// Register adds the kind to the registry. It returns an error if the kind was
+// already registered, or if the registration is incomplete.
+func (r *Registry) Register(reg KindRegistration) error {
+	if reg.Kind == "" || len(reg.APIVersions) == 0 || reg.New == nil {
+		return fmt.Errorf("the kind, apiVersions, and constructor are required to register a kind")
+	}
+
+	if _, found := r.kinds[reg.Kind]; found {
+		return fmt.Errorf("the kind '%v' is already registered", reg.Kind)
+	}
+
+	if reg.Schema == nil {
+		reg.Schema = SchemaFor(reflect.TypeOf(reg.New()))
+
+		// The common fields are handled by the PolicyTransformer, and can be
+		// set in the spec of any kind.
+		addProperties(reg.Schema, reflect.TypeOf(CommonSpec{}))
+	}
+
+	r.kinds[reg.Kind] = reg
+	r.order = append(r.order, reg.Kind)
+
+	return nil
+}
+
+// MustRegister adds the kind to the DefaultRegistry, and panics if that fails.
+// It is meant to be called from init functions.
+func MustRegister(reg KindRegistration) {
+	if err := DefaultRegistry.Register(reg); err != nil {
+		panic(err)
+	}
+}
+
+// Kinds returns the registered kinds, in the order they were registered.
+func (r *Registry) Kinds() []string {
+	return append([]string{}, r.order...)
+}
+
+// Lookup returns the registration for the kind, and whether it was found.
+func (r *Registry) Lookup(kind string) (KindRegistration, bool) {
+	reg, found := r.kinds[kind]
+
+	return reg, found
+}
+
+// lookupVersion returns the registration for the kind, and checks that the
+// apiVersion is supported. An empty apiVersion means the latest one.
+func (r *Registry) lookupVersion(apiVersion, kind string) (KindRegistration, error) {
+	reg, found := r.kinds[kind]
+	if !found {
+		return reg, fmt.Errorf("unknown PolicyTransformer kind '%v', the supported kinds are: %v",
+			kind, strings.Join(r.order, ", "))
+	}
+
+	if apiVersion != "" && !containsString(reg.APIVersions, apiVersion) {
+		return reg, fmt.Errorf("unsupported apiVersion '%v' for kind %v, the supported versions are: %v",
+			apiVersion, kind, strings.Join(reg.APIVersions, ", "))
+	}
+
+	return reg, nil
+}
+
+// LatestVersion returns the latest apiVersion of the kind, or an empty string
+// if the kind is not registered.
+func (r *Registry) LatestVersion(kind string) string {
+	reg, found := r.kinds[kind]
+	if !found {
+		return ""
+	}
+
+	return reg.APIVersions[len(reg.APIVersions)-1]
+}
+
+// Convert changes the spec of a config of the given apiVersion and kind to the
+// latest apiVersion, in place. It returns whether the apiVersion was an older
+// one. An empty apiVersion means the latest one.
+func (r *Registry) Convert(apiVersion, kind string, spec *yaml.RNode) (bool, error) {
+	reg, err := r.lookupVersion(apiVersion, kind)
+	if err != nil {
+		return false, err
+	}
+
+	if apiVersion == "" || apiVersion == r.LatestVersion(kind) {
+		return false, nil
+	}
+
+	if reg.Convert != nil && spec != nil {
+		err = reg.Convert(apiVersion, spec)
+		if err != nil {
+			return true, fmt.Errorf("converting %v from %v: %w", kind, apiVersion, err)
+		}
+	}
+
+	return true, nil
+}
+
+// NewFilter returns the filter for a config of the given apiVersion and kind,
+// with the given name and JSON-encoded spec. An empty apiVersion means the
+// latest one. Configs of older versions are converted, and a deprecation
+// warning is reported.
+func (r *Registry) NewFilter(
+	apiVersion, kind, name string, configSpec []byte, results *framework.Results,
+) (Config, error) {
+	reg, err := r.lookupVersion(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+
+	err = json.Unmarshal(configSpec, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiVersion != "" && apiVersion != r.LatestVersion(kind) {
+		Report(results, NewResult(framework.Warning,
+			fmt.Sprintf("%v is deprecated for %v, use %v instead; the `migrate` command can update "+
+				"config files", apiVersion, kind, r.LatestVersion(kind)),
+			nil, "apiVersion"))
+
+		if data != nil {
+			configSpec, data, err = r.convertJSON(apiVersion, kind, configSpec)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if data != nil {
+		err = validate.AgainstSchema(reg.Schema, data, strfmt.Default)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v spec: %w", kind, err)
+		}
+	}
+
+	config := reg.New()
+
+	err = json.Unmarshal(configSpec, config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Init(name, results)
+
+	return config, nil
+}
+
+// convertJSON converts the JSON-encoded spec to the latest apiVersion, and
+// returns it both encoded and decoded.
+func (r *Registry) convertJSON(apiVersion, kind string, configSpec []byte) ([]byte, interface{}, error) {
+	spec, err := yaml.Parse(string(configSpec))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = r.Convert(apiVersion, kind, spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	converted, err := spec.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data interface{}
+
+	err = json.Unmarshal(converted, &data)
+
+	return converted, data, err
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SchemaFor returns a schema describing the types of the fields in the JSON
+// encoding of the given type. Fields which are not in a struct type are not
+// allowed, so that typos are caught instead of silently ignored.
+func SchemaFor(t reflect.Type) *spec.Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &spec.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{"boolean"}}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &spec.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{"integer"}}}
+	case reflect.Float32, reflect.Float64:
+		return &spec.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{"number"}}}
+	case reflect.String:
+		return &spec.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{"string"}}}
+	case reflect.Slice, reflect.Array:
+		return &spec.Schema{SchemaProps: spec.SchemaProps{
+			Type:  spec.StringOrArray{"array"},
+			Items: &spec.SchemaOrArray{Schema: SchemaFor(t.Elem())},
+		}}
+	case reflect.Map:
+		return &spec.Schema{SchemaProps: spec.SchemaProps{
+			Type:                 spec.StringOrArray{"object"},
+			AdditionalProperties: &spec.SchemaOrBool{Allows: true, Schema: SchemaFor(t.Elem())},
+		}}
+	case reflect.Struct:
+		schema := &spec.Schema{SchemaProps: spec.SchemaProps{
+			Type:                 spec.StringOrArray{"object"},
+			Properties:           make(map[string]spec.Schema),
+			AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+		}}
+
+		addProperties(schema, t)
+
+		return schema
+	default: // interfaces can hold anything
+		return &spec.Schema{}
+	}
+}
+
+// addProperties adds the JSON fields of the struct type to the schema,
+// including the fields of embedded structs.
+func addProperties(schema *spec.Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || field.Type.Kind() == reflect.Func {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addProperties(schema, field.Type)
+
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = *SchemaFor(field.Type)
+	}
+}
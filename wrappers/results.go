@@ -0,0 +1,65 @@
+package wrappers
+
+import (
+	"strconv"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// NewResult returns a Result with the given message about the resource. If the
+// resource still has its path and index annotations, they will be included. The
+// field path is optional.
+func NewResult(severity framework.Severity, msg string, rsrc *yaml.RNode, fieldPath string) *framework.Result {
+	result := &framework.Result{
+		Message:  msg,
+		Severity: severity,
+	}
+
+	if rsrc != nil {
+		result.ResourceRef = &yaml.ResourceIdentifier{
+			TypeMeta: yaml.TypeMeta{
+				APIVersion: rsrc.GetApiVersion(),
+				Kind:       rsrc.GetKind(),
+			},
+			NameMeta: yaml.NameMeta{
+				Name:      rsrc.GetName(),
+				Namespace: rsrc.GetNamespace(),
+			},
+		}
+
+		path, index, _ := kioutil.GetFileAnnotations(rsrc)
+		if path != "" {
+			result.File = &framework.File{Path: path}
+			result.File.Index, _ = strconv.Atoi(index)
+		}
+	}
+
+	if fieldPath != "" {
+		result.Field = &framework.Field{Path: fieldPath}
+	}
+
+	return result
+}
+
+// Report adds the result to the list, if the list is not nil. This allows the
+// wrappers to be used without collecting results.
+func Report(results *framework.Results, result *framework.Result) {
+	if results != nil {
+		*results = append(*results, result)
+	}
+}
+
+// CountWarnings returns the number of results with warning or error severity.
+func CountWarnings(results framework.Results) int {
+	count := 0
+
+	for _, result := range results {
+		if result.Severity == framework.Warning || result.Severity == framework.Error {
+			count++
+		}
+	}
+
+	return count
+}
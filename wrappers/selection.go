@@ -0,0 +1,161 @@
+package wrappers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// UnselectedPassThrough emits inputs which are not selected unchanged.
+	UnselectedPassThrough = "passThrough"
+	// UnselectedDrop removes inputs which are not selected from the output.
+	UnselectedDrop = "drop"
+)
+
+// ResourceSelector matches resources by their group, version, kind, name,
+// namespace, labels, and annotations. The group, version, kind, name, and
+// namespace can be globs (see path.Match), and empty fields match anything.
+// Use "core" as the group to match resources without a group, like ConfigMaps.
+// Labels and annotations must all be present with the given values.
+type ResourceSelector struct {
+	Group       string            `json:"group,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	Kind        string            `json:"kind,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// InputSelection configures which inputs a wrapper wraps. If any include
+// selectors are given, only inputs matching at least one of them are wrapped.
+// Inputs matching any exclude selector are never wrapped. What happens to the
+// other inputs is determined by Unselected.
+type InputSelection struct {
+	Include    []ResourceSelector `json:"include,omitempty"`
+	Exclude    []ResourceSelector `json:"exclude,omitempty"`
+	Unselected string             `json:"unselected,omitempty"`
+}
+
+// Matches returns true if the resource matches all of the selector's fields.
+// It returns an error if one of the globs is malformed.
+func (s ResourceSelector) Matches(rsrc *yaml.RNode) (bool, error) {
+	group, version := "core", rsrc.GetApiVersion()
+	if i := strings.Index(version, "/"); i != -1 {
+		group, version = version[:i], version[i+1:]
+	}
+
+	globs := []struct{ pattern, value string }{
+		{s.Group, group},
+		{s.Version, version},
+		{s.Kind, rsrc.GetKind()},
+		{s.Name, rsrc.GetName()},
+		{s.Namespace, rsrc.GetNamespace()},
+	}
+
+	for _, glob := range globs {
+		if glob.pattern == "" {
+			continue
+		}
+
+		matched, err := path.Match(glob.pattern, glob.value)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern '%v': %w", glob.pattern, err)
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return hasAll(rsrc.GetLabels(), s.Labels) && hasAll(rsrc.GetAnnotations(), s.Annotations), nil
+}
+
+// hasAll returns true if all of the wanted keys are in the map, with the same
+// values.
+func hasAll(actual, wanted map[string]string) bool {
+	for key, val := range wanted {
+		if got, found := actual[key]; !found || got != val {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsSet returns true if any selectors are configured.
+func (s InputSelection) IsSet() bool {
+	return len(s.Include) != 0 || len(s.Exclude) != 0
+}
+
+// Selected returns true if the resource should be wrapped.
+func (s InputSelection) Selected(rsrc *yaml.RNode) (bool, error) {
+	for i, sel := range s.Exclude {
+		matched, err := sel.Matches(rsrc)
+		if err != nil {
+			return false, fmt.Errorf("exclude[%v]: %w", i, err)
+		}
+
+		if matched {
+			return false, nil
+		}
+	}
+
+	if len(s.Include) == 0 {
+		return true, nil
+	}
+
+	for i, sel := range s.Include {
+		matched, err := sel.Matches(rsrc)
+		if err != nil {
+			return false, fmt.Errorf("include[%v]: %w", i, err)
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Select separates the inputs into those which should be wrapped, and those
+// which should not, preserving their order. Inputs which are not selected are
+// reported if they will be dropped.
+func (s InputSelection) Select(
+	operand []*yaml.RNode, results *framework.Results,
+) (selected, unselected []*yaml.RNode, err error) {
+	if s.Unselected != "" && s.Unselected != UnselectedPassThrough && s.Unselected != UnselectedDrop {
+		return operand, nil, fmt.Errorf("unknown value for unselected '%v', must be '%v' or '%v'",
+			s.Unselected, UnselectedPassThrough, UnselectedDrop)
+	}
+
+	selected = make([]*yaml.RNode, 0, len(operand))
+	unselected = make([]*yaml.RNode, 0)
+
+	for _, rsrc := range operand {
+		ok, err := s.Selected(rsrc)
+		if err != nil {
+			return operand, nil, err
+		}
+
+		if ok {
+			selected = append(selected, rsrc)
+
+			continue
+		}
+
+		unselected = append(unselected, rsrc)
+
+		if s.Unselected == UnselectedDrop {
+			Report(results, NewResult(framework.Info,
+				"dropped because it was not selected by include/exclude, and unselected is 'drop'", rsrc, ""))
+		}
+	}
+
+	return selected, unselected, nil
+}
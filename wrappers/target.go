@@ -0,0 +1,98 @@
+package wrappers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// These are the optional fields in the generated objects which are not
+// supported by every targetVersion. They are named after the config fields
+// which cause them to be emitted.
+const (
+	FeatureEvaluationInterval      = "evaluationInterval"
+	FeaturePruneObjectBehavior     = "pruneObjectBehavior"
+	FeatureMetadataComplianceType  = "metadataComplianceType"
+	FeatureNamespaceSelectorLabels = "namespaceSelector.matchLabels/matchExpressions"
+	FeatureBindingOverrides        = "bindingOverrides/subFilter"
+)
+
+// placementV1Beta1 is the apiVersion of Placements in every target. There is
+// no v1 Placement; cluster.open-cluster-management.io/v1 only has ManagedCluster.
+const placementV1Beta1 = "cluster.open-cluster-management.io/v1beta1"
+
+// TargetProfile describes the APIs available in a release of Open Cluster
+// Management (OCM) or Red Hat Advanced Cluster Management (ACM), which the
+// generated objects must work with.
+type TargetProfile struct {
+	// PlacementAPIVersion is the apiVersion of the generated Placements.
+	PlacementAPIVersion string
+	// PlacementRules is whether the deprecated PlacementRule kind is available.
+	// When it is not, Placements are generated instead, and PlacementRules in
+	// the input are an error.
+	PlacementRules bool
+	// Features are the optional fields which can be emitted.
+	Features []string
+}
+
+var (
+	acm25Features = []string{FeatureEvaluationInterval}
+	acm26Features = append(acm25Features, FeaturePruneObjectBehavior)
+	acm27Features = append(acm26Features, FeatureMetadataComplianceType, FeatureNamespaceSelectorLabels)
+	acm28Features = append(acm27Features, FeatureBindingOverrides)
+)
+
+// TargetProfiles are the supported values for `targetVersion` in the configs.
+var TargetProfiles = map[string]TargetProfile{
+	"acm-2.5":  {PlacementAPIVersion: placementV1Beta1, PlacementRules: true, Features: acm25Features},
+	"acm-2.6":  {PlacementAPIVersion: placementV1Beta1, PlacementRules: true, Features: acm26Features},
+	"acm-2.7":  {PlacementAPIVersion: placementV1Beta1, PlacementRules: true, Features: acm27Features},
+	"acm-2.8":  {PlacementAPIVersion: placementV1Beta1, PlacementRules: true, Features: acm28Features},
+	"acm-2.9":  {PlacementAPIVersion: placementV1Beta1, PlacementRules: true, Features: acm28Features},
+	"acm-2.10": {PlacementAPIVersion: placementV1Beta1, PlacementRules: true, Features: acm28Features},
+	"ocm-0.11": {PlacementAPIVersion: placementV1Beta1, PlacementRules: false, Features: acm28Features},
+	"ocm-0.12": {PlacementAPIVersion: placementV1Beta1, PlacementRules: false, Features: acm28Features},
+	"ocm-0.13": {PlacementAPIVersion: placementV1Beta1, PlacementRules: false, Features: acm28Features},
+}
+
+// LookupTarget returns the profile for the targetVersion. When it is empty, the
+// output is not restricted.
+func LookupTarget(targetVersion string) (TargetProfile, error) {
+	if targetVersion == "" {
+		return TargetProfile{PlacementAPIVersion: placementV1Beta1, PlacementRules: true, Features: acm28Features}, nil
+	}
+
+	profile, found := TargetProfiles[targetVersion]
+	if !found {
+		names := make([]string, 0, len(TargetProfiles))
+		for name := range TargetProfiles {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		return profile, fmt.Errorf("unknown targetVersion '%v', must be one of: %v",
+			targetVersion, strings.Join(names, ", "))
+	}
+
+	return profile, nil
+}
+
+// Supports returns true if the feature can be emitted for this target.
+func (p TargetProfile) Supports(feature string) bool {
+	return containsString(p.Features, feature)
+}
+
+// checkFeature returns an error if the feature is used, but not supported by the
+// target. The optional path is the config field which uses it.
+func checkFeature(profile TargetProfile, targetVersion, feature string, used bool, path string) error {
+	if !used || profile.Supports(feature) {
+		return nil
+	}
+
+	if path != "" {
+		return fmt.Errorf("%v is not supported by targetVersion %v, but is used in %v", feature, targetVersion, path)
+	}
+
+	return fmt.Errorf("%v is not supported by targetVersion %v", feature, targetVersion)
+}
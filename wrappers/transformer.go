@@ -0,0 +1,290 @@
+package wrappers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// PolicyTransformer is a kio.Filter which runs the config it is given, like
+// the KRM function. It handles the fields common to every kind of config.
+type PolicyTransformer struct {
+	Config *TransformerConfig
+
+	// ManifestsRoot is the directory that the `manifests` paths in the config
+	// are relative to. When empty, the ManifestsRootAnnotation on the config is
+	// used if present, otherwise they are relative to the working directory.
+	ManifestsRoot string
+
+	// Results collects the warnings and info reported by the wrappers. It may
+	// be nil, in which case they are discarded.
+	Results *framework.Results
+}
+
+// ManifestsRootAnnotation can be set on a config to specify the directory that
+// the `manifests` paths are relative to. The `build` command sets it, because
+// kustomize does not reliably set the working directory of exec functions in
+// nested kustomizations.
+const ManifestsRootAnnotation = "policy-transformer/manifests-root"
+
+// TransformerConfig is a config file for any of the registered kinds.
+type TransformerConfig struct {
+	// ResourceMeta has APIVersion, Kind, and a subset of the k8s metadata fields
+	yaml.ResourceMeta `json:",inline" yaml:",inline"`
+
+	Spec map[string]interface{}
+}
+
+// CommonSpec has the fields that can be set in the spec of any kind of config.
+type CommonSpec struct {
+	FailOnWarnings bool     `json:"failOnWarnings,omitempty"`
+	Manifests      []string `json:"manifests,omitempty"`
+	OutputLayout   string   `json:"outputLayout,omitempty"`
+}
+
+// Filter runs the config on the operand, or on its manifests in generator mode.
+func (t PolicyTransformer) Filter(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+	configSpec, err := json.Marshal(t.Config.Spec)
+	if err != nil {
+		return operand, err
+	}
+
+	var common CommonSpec
+
+	err = json.Unmarshal(configSpec, &common)
+	if err != nil {
+		return operand, err
+	}
+
+	// Counted first, so that warnings about the config itself, like a
+	// deprecated apiVersion, are included.
+	warningsBefore := 0
+	if t.Results != nil {
+		warningsBefore = CountWarnings(*t.Results)
+	}
+
+	transformer, err := t.NewFilter(t.Config.APIVersion, t.Config.Kind, t.Config.Name, configSpec)
+	if err != nil {
+		return operand, err
+	}
+
+	var out, manifests []*yaml.RNode
+
+	if len(common.Manifests) == 0 {
+		out, err = transformer.Filter(operand)
+		if err != nil {
+			return out, err
+		}
+	} else {
+		manifests, err = t.ReadManifests(common.Manifests)
+		if err != nil {
+			return operand, err
+		}
+
+		// In generator mode, only the listed manifests are wrapped, and
+		// everything else is passed through unchanged.
+		wrapped, err := transformer.Filter(manifests)
+		if err != nil {
+			return operand, err
+		}
+
+		out = append(operand, wrapped...)
+	}
+
+	err = SetOutputPaths(CreatedObjects(out, operand, manifests), common.OutputLayout)
+	if err != nil {
+		return out, err
+	}
+
+	if common.FailOnWarnings && t.Results != nil {
+		if count := CountWarnings(*t.Results) - warningsBefore; count != 0 {
+			return out, fmt.Errorf("%v warning(s) were reported, and failOnWarnings is set", count)
+		}
+	}
+
+	return out, nil
+}
+
+// NewFilter returns the filter for the given kind of config from the
+// DefaultRegistry, with the given name and JSON-encoded spec. The filter reports
+// its results to the transformer's.
+func (t PolicyTransformer) NewFilter(apiVersion, kind, name string, configSpec []byte) (kio.Filter, error) {
+	return DefaultRegistry.NewFilter(apiVersion, kind, name, configSpec, t.Results)
+}
+
+// ReadManifests reads the given files and directories, which are relative to
+// the ManifestsRoot. They are read from the local filesystem, so when the
+// function runs in a container, they must be mounted into it.
+func (t PolicyTransformer) ReadManifests(manifests []string) ([]*yaml.RNode, error) {
+	root := t.ManifestsRoot
+	if root == "" {
+		root = t.Config.Annotations[ManifestsRootAnnotation]
+	}
+
+	paths := make([]string, len(manifests))
+
+	for i, path := range manifests {
+		if filepath.IsAbs(path) {
+			paths[i] = path
+		} else {
+			paths[i] = filepath.Join(root, path)
+		}
+
+		if _, err := os.Stat(paths[i]); errors.Is(err, os.ErrNotExist) {
+			// This is the usual error when the function runs in a container,
+			// where the kustomization is not available.
+			return nil, fmt.Errorf("the manifests path '%v' does not exist: when the function runs in a container, "+
+				"the manifests must be mounted into it, or the build command used instead", path)
+		}
+	}
+
+	return ReadInputs(paths)
+}
+
+func ClearInternalAnnotations(operand []*yaml.RNode) ([]*yaml.RNode, error) {
+	for _, rsrc := range operand {
+		internalAnnos := kioutil.GetInternalAnnotations(rsrc)
+		for key := range internalAnnos {
+			_, err := yaml.ClearAnnotation(key).Filter(rsrc)
+			if err != nil {
+				return operand, err
+			}
+		}
+
+		// one more annotation that isn't in `GetInternalAnnotations`
+		_, err := yaml.ClearAnnotation("kustomize.config.k8s.io/id").Filter(rsrc)
+		if err != nil {
+			return operand, err
+		}
+	}
+
+	return operand, nil
+}
+
+// LiftDocumentComments moves the comments on the yaml document containing the
+// resource (for example, a comment at the top of a file separated from the
+// object by a blank line) onto the resource itself. Otherwise, those comments
+// would be lost when the resource is nested inside another object.
+func LiftDocumentComments(rsrc *yaml.RNode) {
+	doc := rsrc.Document()
+	if doc == nil || doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return
+	}
+
+	obj := doc.Content[0]
+
+	if doc.HeadComment != "" {
+		// Head comments on a nested map are not emitted, so the comment is put
+		// on the first key instead.
+		target := obj
+		if obj.Kind == yaml.MappingNode && len(obj.Content) != 0 {
+			target = obj.Content[0]
+		}
+
+		target.HeadComment = joinComments(doc.HeadComment, target.HeadComment)
+		doc.HeadComment = ""
+	}
+
+	if doc.FootComment != "" {
+		obj.FootComment = joinComments(obj.FootComment, doc.FootComment)
+		doc.FootComment = ""
+	}
+}
+
+func joinComments(first, second string) string {
+	if first == "" {
+		return second
+	}
+
+	if second == "" {
+		return first
+	}
+
+	return first + "\n" + second
+}
+
+// GroupInputs clears the internal annotations from the inputs, and groups them
+// for wrapping. When consolidating, all inputs are put in one group with the
+// base name; otherwise each input is put in its own group, with the base name
+// and an index as its name.
+func GroupInputs(operand []*yaml.RNode, consolidate bool, baseName string) (names []string, groups [][]*yaml.RNode, err error) {
+	_, err = ClearInternalAnnotations(operand)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if consolidate {
+		return []string{baseName}, [][]*yaml.RNode{operand}, nil
+	}
+
+	names = make([]string, len(operand))
+	groups = make([][]*yaml.RNode, len(operand))
+
+	for i, rsrc := range operand {
+		names[i] = fmt.Sprintf("%v-%v", baseName, i)
+		groups[i] = []*yaml.RNode{rsrc}
+	}
+
+	return names, groups, nil
+}
+
+// NewSortedMapRNode returns a yaml map node with the given values, with its keys
+// in sorted order. Unlike yaml.NewMapRNode, the output is the same on every run.
+func NewSortedMapRNode(values map[string]string) *yaml.RNode {
+	m := yaml.NewMapRNode(nil)
+
+	for _, key := range yaml.SortedMapKeys(values) {
+		m.YNode().Content = append(m.YNode().Content,
+			yaml.NewStringRNode(key).YNode(), yaml.NewStringRNode(values[key]).YNode())
+	}
+
+	return m
+}
+
+// kustomizationFileNames are the names kustomize recognizes for the file which
+// defines a kustomization.
+var kustomizationFileNames = map[string]bool{
+	"kustomization.yaml": true,
+	"kustomization.yml":  true,
+	"Kustomization":      true,
+}
+
+// ReadInputs reads the resources from the given files and directories. The
+// resources will be annotated with their paths relative to the given inputs.
+// Hidden files (like the `.out.yaml` files in the examples) and kustomization
+// files in directories are skipped, since they are not manifests to wrap.
+func ReadInputs(paths []string) ([]*yaml.RNode, error) {
+	nodes := make([]*yaml.RNode, 0)
+
+	for _, path := range paths {
+		reader := kio.LocalPackageReader{
+			PackagePath:    path,
+			MatchFilesGlob: kio.MatchAll,
+		}
+
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			reader.FileSkipFunc = func(relPath string) bool {
+				base := filepath.Base(relPath)
+
+				return strings.HasPrefix(base, ".") || kustomizationFileNames[base]
+			}
+		}
+
+		read, err := reader.Read()
+		if err != nil {
+			return nodes, err
+		}
+
+		nodes = append(nodes, read...)
+	}
+
+	return nodes, nil
+}